@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/grayjunzi/lenslocked/models"
+	"github.com/grayjunzi/lenslocked/rand"
+	"github.com/joho/godotenv"
+)
+
+// Sends signed billing webhooks to a local server, standing in for the
+// payment provider during development. For example:
+//
+//	go run ./cmd/billing subscription.created 1 sub_123 pro
+//	go run ./cmd/billing payment.failed 1 sub_123 pro
+func main() {
+	if len(os.Args) < 5 {
+		fmt.Println("Usage: billing <event type> <user id> <subscription id> <plan>")
+		return
+	}
+	err := godotenv.Load()
+	if err != nil {
+		panic(err)
+	}
+
+	userID, err := strconv.Atoi(os.Args[2])
+	if err != nil {
+		panic(err)
+	}
+	id, err := rand.String(16)
+	if err != nil {
+		panic(err)
+	}
+	event := models.BillingEvent{
+		ID:             "evt_" + id,
+		Type:           os.Args[1],
+		UserID:         userID,
+		SubscriptionID: os.Args[3],
+		Plan:           os.Args[4],
+		PeriodEndsAt:   time.Now().AddDate(0, 1, 0),
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		panic(err)
+	}
+
+	endpoint := "http://localhost" + os.Getenv("SERVER_ADDRESS") + "/webhooks/billing"
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		panic(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(models.WebhookSignatureHeader, models.SignWebhook(os.Getenv("PAYMENT_WEBHOOK_SECRET"), payload, time.Now()))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+	fmt.Println(resp.Status)
+}
@@ -0,0 +1,35 @@
+package context
+
+import (
+	"context"
+)
+
+const (
+	FlashSuccess = "success"
+	FlashError   = "error"
+)
+
+// FlashMessage is a one-time message shown on the page after a redirect.
+// Message is an i18n message key.
+type FlashMessage struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+const (
+	flashKey key = "flash"
+)
+
+func WithFlash(ctx context.Context, flash *FlashMessage) context.Context {
+	return context.WithValue(ctx, flashKey, flash)
+}
+
+func Flash(ctx context.Context) *FlashMessage {
+	val := ctx.Value(flashKey)
+	flash, ok := val.(*FlashMessage)
+	if !ok {
+		return nil
+	}
+
+	return flash
+}
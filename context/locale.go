@@ -0,0 +1,25 @@
+package context
+
+import (
+	"context"
+
+	"github.com/grayjunzi/lenslocked/i18n"
+)
+
+const (
+	localeKey key = "locale"
+)
+
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey, locale)
+}
+
+func Locale(ctx context.Context) string {
+	val := ctx.Value(localeKey)
+	locale, ok := val.(string)
+	if !ok {
+		return i18n.Default
+	}
+
+	return locale
+}
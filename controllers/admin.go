@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grayjunzi/lenslocked/context"
+	"github.com/grayjunzi/lenslocked/errors"
+	"github.com/grayjunzi/lenslocked/models"
+)
+
+// AdminMiddleware grants access by user ID. Emails are not verified, so
+// matching on them would let anyone sign up with an admin's address.
+type AdminMiddleware struct {
+	AdminUserIDs []int
+}
+
+func (m AdminMiddleware) RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := context.User(r.Context())
+		if user == nil {
+			http.Redirect(w, r, "/signin", http.StatusFound)
+			return
+		}
+		for _, id := range m.AdminUserIDs {
+			if id == user.ID {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	})
+}
+
+type Admin struct {
+	Templates struct {
+		Emails Template
+	}
+	EmailOutboxService *models.EmailOutboxService
+}
+
+func (a Admin) Emails(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		Status string
+		Emails []models.OutboxEmail
+	}
+	data.Status = r.FormValue("status")
+	emails, err := a.EmailOutboxService.List(data.Status, 100)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, t(r, "error.generic"), http.StatusInternalServerError)
+		return
+	}
+	data.Emails = emails
+	a.Templates.Emails.Execute(w, r, data)
+}
+
+func (a Admin) ResendEmail(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	err = a.EmailOutboxService.Resend(id)
+	if err != nil {
+		fmt.Println(err)
+		if errors.Is(err, models.ErrNotResendable) {
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+			return
+		}
+		http.Error(w, t(r, "error.generic"), http.StatusInternalServerError)
+		return
+	}
+	vals := url.Values{
+		"status": {r.FormValue("status")},
+	}
+	http.Redirect(w, r, "/admin/emails?"+vals.Encode(), http.StatusFound)
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/grayjunzi/lenslocked/context"
+	"github.com/grayjunzi/lenslocked/errors"
+	"github.com/grayjunzi/lenslocked/models"
+)
+
+type Billing struct {
+	Templates struct {
+		Show Template
+	}
+	SubscriptionService *models.SubscriptionService
+	PaymentProvider     models.PaymentProvider
+	Flashes             Flashes
+	ServerURL           string
+}
+
+func (b Billing) Show(w http.ResponseWriter, r *http.Request) {
+	user := context.User(r.Context())
+	sub, err := b.SubscriptionService.ForUser(user.ID)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, t(r, "error.generic"), http.StatusInternalServerError)
+		return
+	}
+	var data struct {
+		Subscription *models.Subscription
+		CurrentPlan  models.Plan
+		Plans        []models.Plan
+	}
+	data.Subscription = sub
+	data.CurrentPlan = b.SubscriptionService.ActivePlan(sub, time.Now())
+	data.Plans = models.Plans
+	b.Templates.Show.Execute(w, r, data)
+}
+
+func (b Billing) StartTrial(w http.ResponseWriter, r *http.Request) {
+	user := context.User(r.Context())
+	_, err := b.SubscriptionService.StartTrial(user.ID, r.FormValue("plan"))
+	if err != nil {
+		fmt.Println(err)
+		if errors.Is(err, models.ErrTrialUsed) || errors.Is(err, models.ErrPlanNotFound) {
+			b.Flashes.Set(w, context.FlashError, "error.trial_unavailable")
+			http.Redirect(w, r, "/users/me/billing", http.StatusFound)
+			return
+		}
+		http.Error(w, t(r, "error.generic"), http.StatusInternalServerError)
+		return
+	}
+	b.Flashes.Set(w, context.FlashSuccess, "flash.trial_started")
+	http.Redirect(w, r, "/users/me/billing", http.StatusFound)
+}
+
+func (b Billing) Checkout(w http.ResponseWriter, r *http.Request) {
+	user := context.User(r.Context())
+	plan, err := models.FindPlan(r.FormValue("plan"))
+	if err != nil || plan.Free() {
+		b.Flashes.Set(w, context.FlashError, "error.invalid_plan")
+		http.Redirect(w, r, "/users/me/billing", http.StatusFound)
+		return
+	}
+	// A second checkout would start a second provider subscription, and the
+	// first one would keep billing the user.
+	sub, err := b.SubscriptionService.ForUser(user.ID)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, t(r, "error.generic"), http.StatusInternalServerError)
+		return
+	}
+	if sub.ProviderSubscriptionID != "" {
+		b.Flashes.Set(w, context.FlashError, "error.already_subscribed")
+		http.Redirect(w, r, "/users/me/billing", http.StatusFound)
+		return
+	}
+	checkoutURL, err := b.PaymentProvider.CheckoutURL(models.Checkout{
+		UserID:     user.ID,
+		Email:      user.Email,
+		Plan:       plan,
+		SuccessURL: b.ServerURL + "/users/me/billing",
+		CancelURL:  b.ServerURL + "/users/me/billing",
+	})
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, t(r, "error.generic"), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, checkoutURL, http.StatusFound)
+}
+
+func (b Billing) Cancel(w http.ResponseWriter, r *http.Request) {
+	user := context.User(r.Context())
+	sub, err := b.SubscriptionService.ForUser(user.ID)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, t(r, "error.generic"), http.StatusInternalServerError)
+		return
+	}
+	if sub.ProviderSubscriptionID == "" {
+		http.Redirect(w, r, "/users/me/billing", http.StatusFound)
+		return
+	}
+	err = b.PaymentProvider.CancelSubscription(sub.ProviderSubscriptionID)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, t(r, "error.generic"), http.StatusInternalServerError)
+		return
+	}
+	err = b.SubscriptionService.ScheduleCancel(user.ID)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, t(r, "error.generic"), http.StatusInternalServerError)
+		return
+	}
+	b.Flashes.Set(w, context.FlashSuccess, "flash.subscription_canceled")
+	http.Redirect(w, r, "/users/me/billing", http.StatusFound)
+}
+
+func (b Billing) Webhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	event, err := b.PaymentProvider.ParseEvent(payload, r.Header)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	err = b.SubscriptionService.HandleEvent(*event)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/grayjunzi/lenslocked/context"
+	"github.com/grayjunzi/lenslocked/models"
+)
+
+// emailSamples holds the data each email is previewed with under /dev/emails.
+var emailSamples = map[string]interface{}{
+	"forgot-password": models.ForgotPasswordEmail{
+		ResetURL: "http://localhost:3000/reset-password?token=sample-token",
+	},
+	"notification": models.NotificationEmail{
+		Notification:   sampleNotifications[0],
+		UnsubscribeURL: "http://localhost:3000/unsubscribe?token=sample-token",
+	},
+	"digest": models.DigestEmail{
+		Notifications:  sampleNotifications,
+		UnsubscribeURL: "http://localhost:3000/unsubscribe?token=sample-token",
+	},
+}
+
+var sampleNotifications = []models.Notification{
+	{
+		EventType: models.EventNewComment,
+		Title:     "New comment on \"Wedding day\"",
+		Body:      "Alice: These turned out beautifully!",
+		URL:       "http://localhost:3000/galleries/1",
+	},
+	{
+		EventType: models.EventStorageWarning,
+		Title:     "You have used 90% of your storage",
+		Body:      "Upgrade your plan or remove some photos to keep uploading.",
+		URL:       "http://localhost:3000/users/me/billing",
+	},
+}
+
+type DevEmails struct {
+	Templates struct {
+		Index Template
+	}
+	EmailTemplates *models.EmailTemplates
+}
+
+func (d DevEmails) Index(w http.ResponseWriter, r *http.Request) {
+	type email struct {
+		Name    string
+		Subject string
+		Error   string
+	}
+	var data struct {
+		Emails []email
+	}
+	for _, name := range d.EmailTemplates.Names() {
+		rendered, err := d.render(name, context.Locale(r.Context()))
+		if err != nil {
+			data.Emails = append(data.Emails, email{Name: name, Error: err.Error()})
+			continue
+		}
+		data.Emails = append(data.Emails, email{Name: name, Subject: rendered.Subject})
+	}
+	d.Templates.Index.Execute(w, r, data)
+}
+
+func (d DevEmails) Show(w http.ResponseWriter, r *http.Request) {
+	email, err := d.render(chi.URLParam(r, "name"), context.Locale(r.Context()))
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if r.FormValue("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "Subject: %s\n\n%s\n", email.Subject, email.PlainText)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, email.HTML)
+}
+
+func (d DevEmails) render(name, locale string) (models.Email, error) {
+	sample, ok := emailSamples[name]
+	if !ok {
+		return models.Email{}, fmt.Errorf("no sample data for email %q", name)
+	}
+	return d.EmailTemplates.Render(name, locale, sample)
+}
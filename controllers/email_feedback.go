@@ -0,0 +1,66 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/grayjunzi/lenslocked/models"
+)
+
+// EmailFeedback receives bounce and complaint reports, either as raw DSN/ARF
+// messages piped from an MTA or as JSON from an email provider's webhook.
+// Both endpoints expect "Authorization: Bearer <Secret>".
+type EmailFeedback struct {
+	SuppressionService *models.EmailSuppressionService
+	Secret             string
+}
+
+func (f EmailFeedback) Report(w http.ResponseWriter, r *http.Request) {
+	if !f.authorized(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	feedback, err := models.ParseFeedbackReport(http.MaxBytesReader(w, r.Body, 10<<20))
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	f.record(w, feedback)
+}
+
+func (f EmailFeedback) Events(w http.ResponseWriter, r *http.Request) {
+	if !f.authorized(r) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	var feedback []models.EmailFeedback
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&feedback)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	f.record(w, feedback)
+}
+
+func (f EmailFeedback) record(w http.ResponseWriter, feedback []models.EmailFeedback) {
+	err := f.SuppressionService.Record(feedback)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f EmailFeedback) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || f.Secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(f.Secret)) == 1
+}
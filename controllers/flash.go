@@ -0,0 +1,82 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/grayjunzi/lenslocked/context"
+)
+
+const (
+	CookieFlash = "flash"
+)
+
+// Flashes stores one-time messages in a signed cookie so they survive a
+// redirect, e.g. "preferences saved" after a POST.
+type Flashes struct {
+	Key []byte
+}
+
+// Load moves the flash cookie, if any, into the request context and deletes
+// it so the message is only shown once.
+func (f Flashes) Load(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, err := readCookie(r, CookieFlash)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		deleteCookie(w, CookieFlash)
+
+		flash, ok := f.decode(value)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithFlash(r.Context(), flash)
+		r = r.WithContext(ctx)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Set stores a flash message for the next request. message is an i18n
+// message key.
+func (f Flashes) Set(w http.ResponseWriter, level, message string) {
+	payload, err := json.Marshal(context.FlashMessage{
+		Level:   level,
+		Message: message,
+	})
+	if err != nil {
+		return
+	}
+	value := base64.RawURLEncoding.EncodeToString(payload)
+	setCookie(w, CookieFlash, value+"."+f.sign(value))
+}
+
+func (f Flashes) decode(value string) (*context.FlashMessage, bool) {
+	value, mac, ok := strings.Cut(value, ".")
+	if !ok || len(f.Key) == 0 || !hmac.Equal([]byte(mac), []byte(f.sign(value))) {
+		return nil, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, false
+	}
+	var flash context.FlashMessage
+	err = json.Unmarshal(payload, &flash)
+	if err != nil {
+		return nil, false
+	}
+	return &flash, true
+}
+
+func (f Flashes) sign(value string) string {
+	h := hmac.New(sha256.New, f.Key)
+	h.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/grayjunzi/lenslocked/context"
+	"github.com/grayjunzi/lenslocked/i18n"
+	"github.com/grayjunzi/lenslocked/models"
+)
+
+const (
+	CookieLocale = "locale"
+)
+
+type LocaleMiddleware struct {
+	UserService *models.UserService
+}
+
+// SetLocale picks the request locale from the locale cookie, then the
+// signed in user's preference, then the Accept-Language header. It must run
+// after UserMiddleware.SetUser.
+func (m LocaleMiddleware) SetLocale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var locale string
+		if value, err := readCookie(r, CookieLocale); err == nil {
+			locale = i18n.Normalize(value)
+		}
+		if user := context.User(r.Context()); locale == "" && user != nil {
+			locale = i18n.Normalize(user.Locale)
+		}
+		if locale == "" {
+			locale = i18n.Match(r.Header.Get("Accept-Language"))
+		}
+
+		ctx := context.WithLocale(r.Context(), locale)
+		r = r.WithContext(ctx)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Update switches the locale for this browser and, when signed in, saves it
+// as the user's preference so emails use it too.
+func (m LocaleMiddleware) Update(w http.ResponseWriter, r *http.Request) {
+	locale := i18n.Normalize(r.FormValue("lang"))
+	if locale == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+	cookie := newCookie(CookieLocale, locale)
+	cookie.MaxAge = 365 * 24 * 60 * 60
+	http.SetCookie(w, cookie)
+
+	if user := context.User(r.Context()); user != nil {
+		err := m.UserService.UpdateLocale(user.ID, locale)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	redirect := "/"
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host && ref.Path != "" {
+		redirect = ref.RequestURI()
+	}
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+// t translates key into the locale of the request.
+func t(r *http.Request, key string, args ...interface{}) string {
+	return i18n.T(context.Locale(r.Context()), key, args...)
+}
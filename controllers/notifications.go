@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/grayjunzi/lenslocked/context"
+	"github.com/grayjunzi/lenslocked/errors"
+	"github.com/grayjunzi/lenslocked/models"
+)
+
+type Notifications struct {
+	Templates struct {
+		Preferences Template
+		Unsubscribe Template
+	}
+	NotificationService *models.NotificationService
+	UserService         *models.UserService
+	Flashes             Flashes
+}
+
+func (n Notifications) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		Token string
+		Done  bool
+	}
+	data.Token = r.FormValue("token")
+	data.Done = r.FormValue("done") != ""
+	n.Templates.Unsubscribe.Execute(w, r, data)
+}
+
+// ProcessUnsubscribe handles both the confirmation form and one-click
+// unsubscribe requests that mail providers POST to the List-Unsubscribe URL.
+func (n Notifications) ProcessUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	err := n.NotificationService.Unsubscribe(r.FormValue("token"))
+	if err != nil {
+		fmt.Println(err)
+		if errors.Is(err, models.ErrInvalidUnsubscribeToken) {
+			http.Error(w, t(r, "error.invalid_unsubscribe"), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, t(r, "error.generic"), http.StatusInternalServerError)
+		return
+	}
+	if r.FormValue("List-Unsubscribe") == "One-Click" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, "/unsubscribe?done=1", http.StatusFound)
+}
+
+func (n Notifications) Preferences(w http.ResponseWriter, r *http.Request) {
+	user := context.User(r.Context())
+	prefs, err := n.NotificationService.Preferences(user.ID)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, t(r, "error.generic"), http.StatusInternalServerError)
+		return
+	}
+	type preference struct {
+		Event    string
+		Delivery string
+	}
+	var data struct {
+		Preferences []preference
+		Timezone    string
+	}
+	for _, event := range models.NotificationEvents {
+		data.Preferences = append(data.Preferences, preference{
+			Event:    event,
+			Delivery: prefs[event],
+		})
+	}
+	data.Timezone = user.Timezone
+	n.Templates.Preferences.Execute(w, r, data)
+}
+
+func (n Notifications) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	user := context.User(r.Context())
+	timezone := r.FormValue("timezone")
+	if timezone != "" && timezone != user.Timezone {
+		err := n.UserService.UpdateTimezone(user.ID, timezone)
+		if err != nil {
+			fmt.Println(err)
+			n.Flashes.Set(w, context.FlashError, "error.invalid_timezone")
+			http.Redirect(w, r, "/users/me/notifications", http.StatusFound)
+			return
+		}
+	}
+	for _, event := range models.NotificationEvents {
+		delivery := r.FormValue(event)
+		if delivery == "" {
+			continue
+		}
+		err := n.NotificationService.SetDelivery(user.ID, event, delivery)
+		if err != nil {
+			fmt.Println(err)
+			http.Error(w, t(r, "error.generic"), http.StatusInternalServerError)
+			return
+		}
+	}
+	n.Flashes.Set(w, context.FlashSuccess, "flash.preferences_saved")
+	http.Redirect(w, r, "/users/me/notifications", http.StatusFound)
+}
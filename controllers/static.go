@@ -11,21 +11,22 @@ func StaticHandler(tpl Template) http.HandlerFunc {
 }
 
 func FAQ(tpl Template) http.HandlerFunc {
+	// Questions and answers are i18n message keys.
 	questions := []struct {
 		Question string
 		Answer   string
 	}{
 		{
-			Question: "Is there a free version?",
-			Answer:   "Yes! We offer a free trial for 30 days on any paid plans.",
+			Question: "faq.free_version.question",
+			Answer:   "faq.free_version.answer",
 		},
 		{
-			Question: "What are your support hours?",
-			Answer:   "We have support staff answering emails 24/7, though response times may be abit slower on weekends.",
+			Question: "faq.support_hours.question",
+			Answer:   "faq.support_hours.answer",
 		},
 		{
-			Question: "How do I contact support?",
-			Answer:   `<a href="mailto:supportalenslocked.com">Email us</a>`,
+			Question: "faq.contact_support.question",
+			Answer:   "faq.contact_support.answer",
 		},
 	}
 
@@ -3,5 +3,5 @@ package controllers
 import "net/http"
 
 type Template interface {
-	Execute(w http.ResponseWriter, r *http.Request, data interface{})
+	Execute(w http.ResponseWriter, r *http.Request, data interface{}, errs ...error)
 }
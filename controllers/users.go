@@ -1,11 +1,13 @@
 package controllers
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
 	"net/url"
 
 	"github.com/grayjunzi/lenslocked/context"
+	"github.com/grayjunzi/lenslocked/errors"
 	"github.com/grayjunzi/lenslocked/models"
 )
 
@@ -15,11 +17,15 @@ type Users struct {
 		SignIn         Template
 		ForgotPassword Template
 		CheckYourEmail Template
+		CurrentUser    Template
 	}
 	UserService          *models.UserService
 	SessionService       *models.SessionService
 	EmailService         *models.EmailService
 	PasswordResetService *models.PasswordResetService
+	SuppressionService   *models.EmailSuppressionService
+	Flashes              Flashes
+	ServerURL            string
 }
 
 func (u Users) New(w http.ResponseWriter, r *http.Request) {
@@ -31,12 +37,19 @@ func (u Users) New(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u Users) Create(w http.ResponseWriter, r *http.Request) {
-	email := r.FormValue("email")
+	var data struct {
+		Email string
+	}
+	data.Email = r.FormValue("email")
 	password := r.FormValue("password")
-	user, err := u.UserService.Create(email, password)
+	user, err := u.UserService.Create(data.Email, password)
 	if err != nil {
-		fmt.Println(err)
-		http.Error(w, "Something went wrong.", http.StatusInternalServerError)
+		if errors.Is(err, models.ErrEmailTaken) {
+			err = errors.Field(err, "email", "error.email_taken")
+		} else {
+			fmt.Println(err)
+		}
+		u.Templates.New.Execute(w, r, data, err)
 		return
 	}
 	session, err := u.SessionService.Create(user.ID)
@@ -60,22 +73,25 @@ func (u Users) SignIn(w http.ResponseWriter, r *http.Request) {
 
 func (u Users) ProcessSignIn(w http.ResponseWriter, r *http.Request) {
 	var data struct {
-		Email    string
-		Password string
+		Email string
 	}
 	data.Email = r.FormValue("email")
-	data.Password = r.FormValue("password")
-	user, err := u.UserService.Authenticate(data.Email, data.Email)
+	password := r.FormValue("password")
+	user, err := u.UserService.Authenticate(data.Email, password)
 	if err != nil {
-		fmt.Println(err)
-		http.Error(w, "Something went wrong.", http.StatusInternalServerError)
+		if errors.Is(err, models.ErrInvalidCredentials) {
+			err = errors.Public(err, "error.invalid_credentials")
+		} else {
+			fmt.Println(err)
+		}
+		u.Templates.SignIn.Execute(w, r, data, err)
 		return
 	}
 
 	session, err := u.SessionService.Create(user.ID)
 	if err != nil {
 		fmt.Println(err)
-		http.Error(w, "Something went wrong.", http.StatusInternalServerError)
+		u.Templates.SignIn.Execute(w, r, data, err)
 		return
 	}
 	setCookie(w, CookieSession, session.Token)
@@ -84,7 +100,31 @@ func (u Users) ProcessSignIn(w http.ResponseWriter, r *http.Request) {
 
 func (u Users) CurrentUser(w http.ResponseWriter, r *http.Request) {
 	user := context.User(r.Context())
-	fmt.Fprintf(w, "Current user: %s\n", user.Email)
+	suppression, err := u.SuppressionService.ForEmail(user.Email)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, t(r, "error.generic"), http.StatusInternalServerError)
+		return
+	}
+	var data struct {
+		Email       string
+		Suppression *models.EmailSuppression
+	}
+	data.Email = user.Email
+	data.Suppression = suppression
+	u.Templates.CurrentUser.Execute(w, r, data)
+}
+
+func (u Users) ResumeEmail(w http.ResponseWriter, r *http.Request) {
+	user := context.User(r.Context())
+	err := u.SuppressionService.Remove(user.Email)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, t(r, "error.generic"), http.StatusInternalServerError)
+		return
+	}
+	u.Flashes.Set(w, context.FlashSuccess, "flash.email_resumed")
+	http.Redirect(w, r, "/users/me", http.StatusFound)
 }
 
 func (u Users) ProcessSignOut(w http.ResponseWriter, r *http.Request) {
@@ -98,11 +138,12 @@ func (u Users) ProcessSignOut(w http.ResponseWriter, r *http.Request) {
 	err = u.SessionService.Delete(token)
 	if err != nil {
 		fmt.Println(err)
-		http.Error(w, "Something went wrong", http.StatusInternalServerError)
+		http.Error(w, t(r, "error.generic"), http.StatusInternalServerError)
 		return
 	}
 
 	deleteCookie(w, CookieSession)
+	u.Flashes.Set(w, context.FlashSuccess, "flash.signed_out")
 	http.Redirect(w, r, "/signin", http.StatusFound)
 }
 
@@ -119,19 +160,22 @@ func (u Users) ProcessForgotPassword(w http.ResponseWriter, r *http.Request) {
 		Email string
 	}
 	data.Email = r.FormValue("email")
-	passwordReset, err := u.PasswordResetService.Create(data.Email)
-	if err != nil {
+	_, err := u.PasswordResetService.Create(data.Email, func(tx *sql.Tx, user *models.User, pwReset *models.PasswordReset) error {
+		vals := url.Values{
+			"token": {pwReset.Token},
+		}
+		resetURL := u.ServerURL + "/reset-password?" + vals.Encode()
+		locale := user.Locale
+		if locale == "" {
+			locale = context.Locale(r.Context())
+		}
+		return u.EmailService.ForgotPassword(tx, locale, user.Email, resetURL)
+	})
+	// Unknown addresses get the same page as known ones, so the form cannot be
+	// used to find out who has an account.
+	if err != nil && !errors.Is(err, models.ErrUserNotFound) {
 		fmt.Println(err)
-		http.Error(w, "Something went wrong.", http.StatusInternalServerError)
-		return
-	}
-
-	vals := url.Values{
-		"token": {passwordReset.Token},
-	}
-	resetURL := "http://localhost:3000/reset-password?" + vals.Encode()
-	err = u.EmailService.ForgotPassword(data.Email, resetURL)
-	if err != nil {
+		u.Templates.ForgotPassword.Execute(w, r, data, err)
 		return
 	}
 
@@ -0,0 +1,6 @@
+package emails
+
+import "embed"
+
+//go:embed *
+var FS embed.FS
@@ -0,0 +1,11 @@
+package errors
+
+import "errors"
+
+// These variables are used to give us access to existing functions in the
+// std lib errors package, so importing this package is enough in files that
+// also need Public or Field.
+var (
+	As = errors.As
+	Is = errors.Is
+)
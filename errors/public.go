@@ -0,0 +1,40 @@
+package errors
+
+// Public wraps the original error with a new error that has a
+// `Public() string` method that will return a message that is acceptable to
+// display to the public. This error can also be unwrapped using the
+// traditional `errors` package approach.
+//
+// msg is an i18n message key, so the message is translated into the locale
+// of whoever ends up seeing it.
+func Public(err error, msg string) error {
+	return publicError{err: err, msg: msg}
+}
+
+// Field is like Public, but ties the message to a form field so templates
+// can show it next to the input instead of at the top of the page.
+func Field(err error, field, msg string) error {
+	return publicError{err: err, msg: msg, field: field}
+}
+
+type publicError struct {
+	err   error
+	msg   string
+	field string
+}
+
+func (pe publicError) Error() string {
+	return pe.err.Error()
+}
+
+func (pe publicError) Public() string {
+	return pe.msg
+}
+
+func (pe publicError) Field() string {
+	return pe.field
+}
+
+func (pe publicError) Unwrap() error {
+	return pe.err
+}
@@ -0,0 +1,117 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+const (
+	English = "en"
+	Chinese = "zh"
+)
+
+// Default is used when nothing the client asks for is supported.
+const Default = English
+
+// Supported lists the locales with a catalog, in matching preference order.
+var Supported = []string{English, Chinese}
+
+//go:embed *.json
+var catalogFS embed.FS
+
+var (
+	catalogs = mustLoadCatalogs()
+	matcher  = language.NewMatcher([]language.Tag{language.English, language.Chinese})
+)
+
+func mustLoadCatalogs() map[string]map[string]string {
+	catalogs := make(map[string]map[string]string)
+	for _, locale := range Supported {
+		b, err := catalogFS.ReadFile(locale + ".json")
+		if err != nil {
+			panic(fmt.Errorf("i18n: %w", err))
+		}
+		messages := make(map[string]string)
+		err = json.Unmarshal(b, &messages)
+		if err != nil {
+			panic(fmt.Errorf("i18n: %s: %w", path.Join("i18n", locale+".json"), err))
+		}
+		catalogs[locale] = messages
+	}
+	return catalogs
+}
+
+// Normalize returns the supported locale for lang, such as "zh" for
+// "zh-CN", or an empty string if lang is not supported.
+func Normalize(lang string) string {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		return ""
+	}
+	base, _ := tag.Base()
+	for _, locale := range Supported {
+		if base.String() == locale {
+			return locale
+		}
+	}
+	return ""
+}
+
+// Match picks the best supported locale for an Accept-Language header.
+func Match(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return Default
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return Default
+	}
+	return Supported[index]
+}
+
+// T returns the message for key in locale, formatted with args. Missing
+// messages fall back to the default locale and then to the key itself.
+func T(locale, key string, args ...interface{}) string {
+	msg, ok := catalogs[locale][key]
+	if !ok {
+		msg, ok = catalogs[Default][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return printer(locale).Sprintf(msg, args...)
+}
+
+func FormatDate(locale string, t time.Time) string {
+	return t.Format(T(locale, "format.date"))
+}
+
+func FormatDateTime(locale string, t time.Time) string {
+	return t.Format(T(locale, "format.datetime"))
+}
+
+// FormatNumber formats n with the grouping and decimal separators of
+// locale. Floats are shown with two decimals.
+func FormatNumber(locale string, n interface{}) string {
+	switch n.(type) {
+	case float32, float64:
+		return printer(locale).Sprintf("%.2f", n)
+	default:
+		return printer(locale).Sprintf("%d", n)
+	}
+}
+
+func printer(locale string) *message.Printer {
+	return message.NewPrinter(language.Make(strings.ToLower(locale)))
+}
@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/csrf"
 	"github.com/grayjunzi/lenslocked/controllers"
+	"github.com/grayjunzi/lenslocked/emails"
 	"github.com/grayjunzi/lenslocked/migrations"
 	"github.com/grayjunzi/lenslocked/models"
 	"github.com/grayjunzi/lenslocked/templates"
@@ -17,15 +21,25 @@ import (
 )
 
 type config struct {
-	PSQL models.PostgresConfig
-	SMTP models.SMTPConfig
-	CSRF struct {
+	PSQL    models.PostgresConfig
+	Mail    models.MailerConfig
+	DKIM    models.DKIMConfig
+	Payment models.HostedPaymentConfig
+	CSRF    struct {
 		Key    string
 		Secure bool
 	}
+	Flash struct {
+		Key string
+	}
 	Server struct {
 		Address string
+		URL     string
+		Dev     bool
 	}
+	AdminUserIDs       []int
+	UnsubscribeSecret  string
+	EmailWebhookSecret string
 }
 
 func loadEnvConfig() (config, error) {
@@ -37,22 +51,79 @@ func loadEnvConfig() (config, error) {
 
 	cfg.PSQL = models.DefaultPostgresConfig()
 
-	smtpPort := os.Getenv("SMTP_PORT")
-	port, err := strconv.Atoi(smtpPort)
-	if err != nil {
-		return cfg, err
+	cfg.Mail.Transport = os.Getenv("MAIL_TRANSPORT")
+	switch cfg.Mail.Transport {
+	case models.MailerSMTP, models.MailerFile, models.MailerStdout:
+	default:
+		return cfg, fmt.Errorf("MAIL_TRANSPORT: unknown transport %q", cfg.Mail.Transport)
+	}
+	cfg.Mail.Dir = os.Getenv("MAIL_DIR")
+	if cfg.Mail.Transport == models.MailerSMTP {
+		smtpPort := os.Getenv("SMTP_PORT")
+		port, err := strconv.Atoi(smtpPort)
+		if err != nil {
+			return cfg, fmt.Errorf("SMTP_PORT: %w", err)
+		}
+		cfg.Mail.SMTP = models.SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+		}
+	}
+
+	cfg.DKIM.Domain = os.Getenv("DKIM_DOMAIN")
+	cfg.DKIM.Selector = os.Getenv("DKIM_SELECTOR")
+	if keyFile := os.Getenv("DKIM_PRIVATE_KEY_FILE"); keyFile != "" {
+		cfg.DKIM.PrivateKey, err = os.ReadFile(keyFile)
+		if err != nil {
+			return cfg, fmt.Errorf("DKIM_PRIVATE_KEY_FILE: %w", err)
+		}
+		if cfg.DKIM.Domain == "" || cfg.DKIM.Selector == "" {
+			return cfg, fmt.Errorf("DKIM_DOMAIN and DKIM_SELECTOR are required with DKIM_PRIVATE_KEY_FILE")
+		}
+	}
+	cfg.UnsubscribeSecret = os.Getenv("UNSUBSCRIBE_SECRET")
+	if cfg.UnsubscribeSecret == "" {
+		return cfg, fmt.Errorf("UNSUBSCRIBE_SECRET is required")
+	}
+	cfg.EmailWebhookSecret = os.Getenv("EMAIL_WEBHOOK_SECRET")
+
+	cfg.Payment = models.HostedPaymentConfig{
+		CheckoutURL:   os.Getenv("PAYMENT_CHECKOUT_URL"),
+		APIURL:        os.Getenv("PAYMENT_API_URL"),
+		APIKey:        os.Getenv("PAYMENT_API_KEY"),
+		WebhookSecret: os.Getenv("PAYMENT_WEBHOOK_SECRET"),
 	}
-	cfg.SMTP = models.SMTPConfig{
-		Host:     os.Getenv("SMTP_HOST"),
-		Port:     port,
-		Username: os.Getenv("SMTP_USERNAME"),
-		Password: os.Getenv("SMTP_PASSWORD"),
+	if cfg.Payment.WebhookSecret == "" {
+		return cfg, fmt.Errorf("PAYMENT_WEBHOOK_SECRET is required")
 	}
 
 	cfg.CSRF.Key = os.Getenv("CSRF_KEY")
 	cfg.CSRF.Secure = false
 
+	cfg.Flash.Key = os.Getenv("FLASH_KEY")
+	if cfg.Flash.Key == "" {
+		return cfg, fmt.Errorf("FLASH_KEY is required")
+	}
+
 	cfg.Server.Address = os.Getenv("SERVER_ADDRESS")
+	cfg.Server.URL = os.Getenv("SERVER_URL")
+	if cfg.Server.URL == "" {
+		cfg.Server.URL = "http://localhost" + cfg.Server.Address
+	}
+	cfg.Server.Dev = os.Getenv("APP_ENV") == "development"
+
+	for _, id := range strings.Split(os.Getenv("ADMIN_USER_IDS"), ",") {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+		userID, err := strconv.Atoi(id)
+		if err != nil {
+			return cfg, fmt.Errorf("ADMIN_USER_IDS: %w", err)
+		}
+		cfg.AdminUserIDs = append(cfg.AdminUserIDs, userID)
+	}
 
 	return cfg, nil
 }
@@ -87,7 +158,46 @@ func main() {
 		DB: db,
 	}
 
-	emailService := models.NewEmailService(cfg.SMTP)
+	emailTemplates, err := models.ParseEmailTemplates(emails.FS)
+	if err != nil {
+		panic(err)
+	}
+	mailer, err := models.NewMailer(cfg.Mail)
+	if err != nil {
+		panic(err)
+	}
+	emailService := models.NewEmailService(mailer, emailTemplates)
+	if cfg.DKIM.PrivateKey != nil {
+		emailService.DKIM, err = models.NewDKIMSigner(cfg.DKIM)
+		if err != nil {
+			panic(err)
+		}
+	}
+	suppressionService := &models.EmailSuppressionService{
+		DB: db,
+	}
+	emailOutboxService := &models.EmailOutboxService{
+		DB:           db,
+		EmailService: emailService,
+		Suppressions: suppressionService,
+	}
+
+	notificationService := &models.NotificationService{
+		DB:           db,
+		EmailService: emailService,
+		ServerURL:    cfg.Server.URL,
+		Secret:       []byte(cfg.UnsubscribeSecret),
+	}
+
+	subscriptionService := &models.SubscriptionService{
+		DB: db,
+	}
+
+	paymentProvider := models.NewHostedPaymentProvider(cfg.Payment)
+
+	flashes := controllers.Flashes{
+		Key: []byte(cfg.Flash.Key),
+	}
 
 	// 设置控制器
 	usersController := controllers.Users{
@@ -95,6 +205,9 @@ func main() {
 		SessionService:       sessionService,
 		PasswordResetService: passwordResetService,
 		EmailService:         emailService,
+		SuppressionService:   suppressionService,
+		Flashes:              flashes,
+		ServerURL:            cfg.Server.URL,
 	}
 	usersController.Templates.New = views.Must(views.ParseFS(
 		templates.FS,
@@ -108,12 +221,74 @@ func main() {
 		templates.FS,
 		"forgot-password.gohtml", "tailwind.gohtml",
 	))
+	usersController.Templates.CheckYourEmail = views.Must(views.ParseFS(
+		templates.FS,
+		"check-your-email.gohtml", "tailwind.gohtml",
+	))
+	usersController.Templates.CurrentUser = views.Must(views.ParseFS(
+		templates.FS,
+		"current-user.gohtml", "tailwind.gohtml",
+	))
+
+	billingController := controllers.Billing{
+		SubscriptionService: subscriptionService,
+		PaymentProvider:     paymentProvider,
+		Flashes:             flashes,
+		ServerURL:           cfg.Server.URL,
+	}
+	billingController.Templates.Show = views.Must(views.ParseFS(
+		templates.FS,
+		"billing.gohtml", "tailwind.gohtml",
+	))
+
+	devEmailsController := controllers.DevEmails{
+		EmailTemplates: emailTemplates,
+	}
+	devEmailsController.Templates.Index = views.Must(views.ParseFS(
+		templates.FS,
+		"dev-emails.gohtml", "tailwind.gohtml",
+	))
+
+	notificationsController := controllers.Notifications{
+		NotificationService: notificationService,
+		UserService:         userService,
+		Flashes:             flashes,
+	}
+	notificationsController.Templates.Preferences = views.Must(views.ParseFS(
+		templates.FS,
+		"notifications.gohtml", "tailwind.gohtml",
+	))
+	notificationsController.Templates.Unsubscribe = views.Must(views.ParseFS(
+		templates.FS,
+		"unsubscribe.gohtml", "tailwind.gohtml",
+	))
+
+	emailFeedbackController := controllers.EmailFeedback{
+		SuppressionService: suppressionService,
+		Secret:             cfg.EmailWebhookSecret,
+	}
+
+	adminController := controllers.Admin{
+		EmailOutboxService: emailOutboxService,
+	}
+	adminController.Templates.Emails = views.Must(views.ParseFS(
+		templates.FS,
+		"admin-emails.gohtml", "tailwind.gohtml",
+	))
 
 	// 设置中间件
 	userMiddleware := controllers.UserMiddleware{
 		SesionService: sessionService,
 	}
 
+	localeMiddleware := controllers.LocaleMiddleware{
+		UserService: userService,
+	}
+
+	adminMiddleware := controllers.AdminMiddleware{
+		AdminUserIDs: cfg.AdminUserIDs,
+	}
+
 	csrfMiddleware := csrf.Protect(
 		[]byte(cfg.CSRF.Key),
 		csrf.Secure(cfg.CSRF.Secure),
@@ -121,8 +296,11 @@ func main() {
 
 	// 设置路由
 	r := chi.NewRouter()
+	r.Use(controllers.SkipCSRF("/webhooks/", "/unsubscribe"))
 	r.Use(csrfMiddleware)
 	r.Use(userMiddleware.SetUser)
+	r.Use(localeMiddleware.SetLocale)
+	r.Use(flashes.Load)
 	r.Get("/", controllers.StaticHandler(views.Must(views.ParseFS(
 		templates.FS,
 		"home.gohtml", "tailwind.gohtml",
@@ -141,19 +319,48 @@ func main() {
 	r.Get("/signin", usersController.SignIn)
 	r.Post("/signin", usersController.ProcessSignIn)
 	r.Post("/signout", usersController.ProcessSignOut)
+	r.Post("/locale", localeMiddleware.Update)
 	r.Get("/forgot-password", usersController.ForgotPassword)
 	r.Post("/forgot-password", usersController.ProcessForgotPassword)
+	r.Get("/unsubscribe", notificationsController.Unsubscribe)
+	r.Post("/unsubscribe", notificationsController.ProcessUnsubscribe)
 
 	// r.Get("/users/me", usersController.CurrentUser)
 	r.Route("/users/me", func(r chi.Router) {
 		r.Use(userMiddleware.RequireUser)
 		r.Get("/", usersController.CurrentUser)
+		r.Post("/email/resume", usersController.ResumeEmail)
+		r.Get("/billing", billingController.Show)
+		r.Post("/billing/trial", billingController.StartTrial)
+		r.Post("/billing/checkout", billingController.Checkout)
+		r.Post("/billing/cancel", billingController.Cancel)
+		r.Get("/notifications", notificationsController.Preferences)
+		r.Post("/notifications", notificationsController.UpdatePreferences)
+	})
+
+	r.Post("/webhooks/billing", billingController.Webhook)
+	r.Post("/webhooks/email/report", emailFeedbackController.Report)
+	r.Post("/webhooks/email/events", emailFeedbackController.Events)
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(adminMiddleware.RequireAdmin)
+		r.Get("/emails", adminController.Emails)
+		r.Post("/emails/{id}/resend", adminController.ResendEmail)
 	})
 
+	if cfg.Server.Dev {
+		r.Get("/dev/emails", devEmailsController.Index)
+		r.Get("/dev/emails/{name}", devEmailsController.Show)
+	}
+
 	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 	})
 
+	// 启动后台任务
+	go emailOutboxService.Run(context.Background(), 10*time.Second)
+	go notificationService.RunDigests(context.Background(), 15*time.Minute)
+
 	// 启动服务
 	fmt.Printf("Starting the server on %s ...\n", cfg.Server.Address)
 	err = http.ListenAndServe(cfg.Server.Address, r)
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE subscriptions (
+    id SERIAL PRIMARY KEY,
+    user_id INT UNIQUE REFERENCES users (id) ON DELETE CASCADE,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL,
+    provider_subscription_id TEXT UNIQUE,
+    trial_used BOOLEAN NOT NULL DEFAULT FALSE,
+    trial_ends_at TIMESTAMPTZ,
+    current_period_ends_at TIMESTAMPTZ,
+    grace_ends_at TIMESTAMPTZ,
+    cancel_at_period_end BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE billing_events (
+    id SERIAL PRIMARY KEY,
+    provider_event_id TEXT UNIQUE NOT NULL,
+    type TEXT NOT NULL,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE billing_events;
+DROP TABLE subscriptions;
+-- +goose StatementEnd
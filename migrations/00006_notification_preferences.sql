@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE notification_preferences (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL,
+    delivery TEXT NOT NULL,
+    UNIQUE (user_id, event_type)
+);
+
+ALTER TABLE email_outbox ADD COLUMN unsubscribe_url TEXT NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE email_outbox DROP COLUMN unsubscribe_url;
+DROP TABLE notification_preferences;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE email_outbox ADD COLUMN sensitive BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Emails without an unsubscribe link are password resets, which carry the
+-- raw reset token.
+UPDATE email_outbox SET sensitive = TRUE WHERE unsubscribe_url = '';
+UPDATE email_outbox SET plain_text = '', html = ''
+WHERE status = 'sent' OR (sensitive AND status <> 'pending');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE email_outbox DROP COLUMN sensitive;
+-- +goose StatementEnd
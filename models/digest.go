@@ -0,0 +1,166 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DigestHour is the local hour of day at which daily digests go out.
+const DigestHour = 8
+
+// RunDigests sends due digests every interval until ctx is canceled.
+func (ns *NotificationService) RunDigests(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, err := ns.SendDigests(time.Now())
+		if err != nil {
+			log.Printf("digests: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SendDigests queues one digest email per user for the notifications that
+// arrived before the user's most recent digest time, and returns how many
+// digests were queued. Notifications arriving later wait for the next day.
+// Failures for a single user are logged and retried on the next run.
+func (ns *NotificationService) SendDigests(now time.Time) (int, error) {
+	type recipient struct {
+		userID   int
+		email    string
+		timezone string
+		locale   string
+	}
+	rows, err := ns.DB.Query(`
+		SELECT DISTINCT users.id, users.email, users.timezone, users.locale
+		FROM notifications
+		JOIN users ON users.id = notifications.user_id
+		WHERE notifications.digested_at IS NULL;
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("send digests: %w", err)
+	}
+	var recipients []recipient
+	for rows.Next() {
+		var r recipient
+		err = rows.Scan(&r.userID, &r.email, &r.timezone, &r.locale)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("send digests: %w", err)
+		}
+		recipients = append(recipients, r)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("send digests: %w", err)
+	}
+
+	sent := 0
+	for _, r := range recipients {
+		ok, err := ns.sendDigest(r.userID, userLocale(r.locale), r.email, digestCutoff(now, r.timezone))
+		if err != nil {
+			// One user's failure should not hold up everyone else's digest.
+			log.Printf("send digests: user %d: %v", r.userID, err)
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+func (ns *NotificationService) sendDigest(userID int, locale, email string, cutoff time.Time) (bool, error) {
+	tx, err := ns.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Only events the user still wants in a digest are included, in case the
+	// preference changed after the notification was stored.
+	rows, err := tx.Query(`
+		SELECT notifications.id, notifications.event_type, notifications.title,
+			notifications.body, notifications.url, notifications.created_at
+		FROM notifications
+		JOIN notification_preferences ON notification_preferences.user_id = notifications.user_id
+			AND notification_preferences.event_type = notifications.event_type
+		WHERE notifications.user_id = $1 AND notifications.digested_at IS NULL
+			AND notifications.created_at < $2 AND notification_preferences.delivery = $3
+		ORDER BY notifications.created_at
+		FOR UPDATE OF notifications SKIP LOCKED;
+	`, userID, cutoff, DeliveryDigest)
+	if err != nil {
+		return false, err
+	}
+	var notifications []Notification
+	for rows.Next() {
+		n := Notification{
+			UserID: userID,
+		}
+		err = rows.Scan(&n.ID, &n.EventType, &n.Title, &n.Body, &n.URL, &n.CreatedAt)
+		if err != nil {
+			rows.Close()
+			return false, err
+		}
+		notifications = append(notifications, n)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return false, err
+	}
+	if len(notifications) == 0 {
+		return false, nil
+	}
+
+	err = ns.EmailService.Digest(tx, locale, email, notifications, ns.unsubscribeURL(userID, EventDigest))
+	if err != nil {
+		return false, err
+	}
+	err = markDigested(tx, notifications)
+	if err != nil {
+		return false, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func markDigested(tx *sql.Tx, notifications []Notification) error {
+	for _, n := range notifications {
+		_, err := tx.Exec(`
+			UPDATE notifications
+			SET digested_at = NOW()
+			WHERE id = $1;
+		`, n.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// digestCutoff returns the most recent DigestHour, in the user's timezone,
+// that is not after now.
+func digestCutoff(now time.Time, timezone string) time.Time {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	cutoff := time.Date(local.Year(), local.Month(), local.Day(), DigestHour, 0, 0, 0, loc)
+	if cutoff.After(local) {
+		cutoff = cutoff.AddDate(0, 0, -1)
+	}
+	return cutoff
+}
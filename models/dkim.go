@@ -0,0 +1,178 @@
+package models
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dkimHeaders lists the headers signed when present, in signing order.
+var dkimHeaders = []string{
+	"From", "To", "Subject", "Date", "Message-ID", "MIME-Version", "Content-Type",
+	"List-Unsubscribe", "List-Unsubscribe-Post",
+}
+
+type DKIMConfig struct {
+	Domain     string
+	Selector   string
+	PrivateKey []byte
+}
+
+// DKIMSigner adds an rsa-sha256 DKIM-Signature header to outgoing messages
+// using relaxed/relaxed canonicalization (RFC 6376).
+type DKIMSigner struct {
+	domain   string
+	selector string
+	key      *rsa.PrivateKey
+}
+
+func NewDKIMSigner(config DKIMConfig) (*DKIMSigner, error) {
+	if config.Domain == "" || config.Selector == "" {
+		return nil, errors.New("new dkim signer: domain and selector are required")
+	}
+	block, _ := pem.Decode(config.PrivateKey)
+	if block == nil {
+		return nil, errors.New("new dkim signer: no PEM data found")
+	}
+	var key *rsa.PrivateKey
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		k, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("new dkim signer: %w", err)
+		}
+		key = k
+	case "PRIVATE KEY":
+		k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("new dkim signer: %w", err)
+		}
+		rsaKey, ok := k.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("new dkim signer: only RSA keys are supported")
+		}
+		key = rsaKey
+	default:
+		return nil, fmt.Errorf("new dkim signer: unsupported PEM block %q", block.Type)
+	}
+	return &DKIMSigner{
+		domain:   config.Domain,
+		selector: config.Selector,
+		key:      key,
+	}, nil
+}
+
+// Sign returns msg with a DKIM-Signature header prepended. msg must use CRLF
+// line endings, as written by mail.Message.
+func (s *DKIMSigner) Sign(msg []byte) ([]byte, error) {
+	header, body, ok := bytes.Cut(msg, []byte("\r\n\r\n"))
+	if !ok {
+		header, body = msg, nil
+	}
+	bodyHash := sha256.Sum256(dkimRelaxedBody(body))
+	fields := dkimHeaderFields(header)
+
+	var names []string
+	var signed bytes.Buffer
+	for _, name := range dkimHeaders {
+		field, ok := fields[strings.ToLower(name)]
+		if !ok {
+			continue
+		}
+		names = append(names, strings.ToLower(name))
+		signed.WriteString(dkimRelaxedHeader(field))
+		signed.WriteString("\r\n")
+	}
+
+	value := fmt.Sprintf("v=1; a=rsa-sha256; c=relaxed/relaxed; d=%s; s=%s; t=%s; h=%s; bh=%s; b=",
+		s.domain, s.selector, strconv.FormatInt(time.Now().Unix(), 10),
+		strings.Join(names, ":"), base64.StdEncoding.EncodeToString(bodyHash[:]))
+	signed.WriteString(dkimRelaxedHeader("DKIM-Signature: " + value))
+
+	digest := sha256.Sum256(signed.Bytes())
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("dkim sign: %w", err)
+	}
+
+	var out bytes.Buffer
+	out.WriteString("DKIM-Signature: ")
+	out.WriteString(value)
+	out.WriteString(base64.StdEncoding.EncodeToString(sig))
+	out.WriteString("\r\n")
+	out.Write(msg)
+	return out.Bytes(), nil
+}
+
+// dkimHeaderFields returns the last occurrence of each header field, keyed
+// by lower case name, with folding preserved.
+func dkimHeaderFields(header []byte) map[string]string {
+	fields := make(map[string]string)
+	var current string
+	flush := func() {
+		if current == "" {
+			return
+		}
+		name, _, _ := strings.Cut(current, ":")
+		fields[strings.ToLower(strings.TrimSpace(name))] = current
+	}
+	for _, line := range strings.Split(string(header), "\r\n") {
+		if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			current += "\r\n" + line
+			continue
+		}
+		flush()
+		current = line
+	}
+	flush()
+	return fields
+}
+
+func dkimRelaxedHeader(field string) string {
+	name, value, _ := strings.Cut(field, ":")
+	value = strings.ReplaceAll(value, "\r\n", "")
+	value = strings.Join(strings.FieldsFunc(value, dkimIsWSP), " ")
+	return strings.ToLower(strings.TrimSpace(name)) + ":" + value
+}
+
+func dkimRelaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		line = strings.TrimRightFunc(line, dkimIsWSP)
+		var b strings.Builder
+		inWSP := false
+		for _, r := range line {
+			if dkimIsWSP(r) {
+				inWSP = true
+				continue
+			}
+			if inWSP {
+				b.WriteByte(' ')
+				inWSP = false
+			}
+			b.WriteRune(r)
+		}
+		lines[i] = b.String()
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func dkimIsWSP(r rune) bool {
+	return r == ' ' || r == '\t'
+}
@@ -0,0 +1,200 @@
+package models
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/go-mail/mail/v2"
+	"github.com/grayjunzi/lenslocked/i18n"
+)
+
+// RFC 6376 section 3.4.5.
+func TestDKIMRelaxedHeader(t *testing.T) {
+	header := "A: X\r\nB : Y\t\r\n\tZ  \r\n"
+	fields := dkimHeaderFields([]byte(strings.TrimSuffix(header, "\r\n")))
+
+	tests := map[string]string{
+		"a": "a:X",
+		"b": "b:Y Z",
+	}
+	for name, want := range tests {
+		field, ok := fields[name]
+		if !ok {
+			t.Fatalf("header %q not found", name)
+		}
+		if got := dkimRelaxedHeader(field); got != want {
+			t.Errorf("dkimRelaxedHeader(%q) = %q, want %q", field, got, want)
+		}
+	}
+}
+
+// RFC 6376 section 3.4.5 and the empty body rules in section 3.4.4.
+func TestDKIMRelaxedBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "rfc example",
+			body: " C \r\nD \t E\r\n\r\n\r\n",
+			want: " C\r\nD E\r\n",
+		},
+		{
+			name: "empty",
+			body: "",
+			want: "",
+		},
+		{
+			name: "only blank lines",
+			body: "\r\n\r\n",
+			want: "",
+		},
+		{
+			name: "missing final crlf",
+			body: "hello",
+			want: "hello\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(dkimRelaxedBody([]byte(tt.body)))
+			if got != tt.want {
+				t.Errorf("dkimRelaxedBody(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDKIMSign(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewDKIMSigner(DKIMConfig{
+		Domain:   "lenslocked.com",
+		Selector: "mail",
+		PrivateKey: pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		}),
+	})
+	if err != nil {
+		t.Fatalf("NewDKIMSigner() err = %v", err)
+	}
+
+	subject := i18n.T(i18n.Chinese, "email.digest.subject")
+	msg := mail.NewMessage()
+	msg.SetHeader("From", "support@lenslocked.com")
+	msg.SetHeader("To", "jane@example.com")
+	msg.SetHeader("Subject", subject)
+	msg.SetHeader("Message-ID", "<abc@lenslocked.com>")
+	msg.SetBody("text/plain", "hello  world \n\n\n")
+	var buf bytes.Buffer
+	_, err = msg.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// go-mail splits long encoded subjects into several encoded words on one
+	// line. Fold between them, as other writers and relays may.
+	raw := strings.Replace(buf.String(), "?= =?UTF-8?", "?=\r\n =?UTF-8?", 1)
+	if raw == buf.String() {
+		t.Fatalf("subject %q was not split into encoded words", subject)
+	}
+
+	signed, err := signer.Sign([]byte(raw))
+	if err != nil {
+		t.Fatalf("Sign() err = %v", err)
+	}
+	if !bytes.HasSuffix(signed, []byte(raw)) {
+		t.Fatalf("Sign() changed the message")
+	}
+	if err := dkimVerify(&key.PublicKey, signed); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	tampered := bytes.Replace(signed, []byte("hello"), []byte("howdy"), 1)
+	if err := dkimVerify(&key.PublicKey, tampered); err == nil {
+		t.Errorf("verify succeeded for a tampered body")
+	}
+	tampered = bytes.Replace(signed, []byte("jane@example.com"), []byte("john@example.com"), 1)
+	if err := dkimVerify(&key.PublicKey, tampered); err == nil {
+		t.Errorf("verify succeeded for a tampered header")
+	}
+	// Relaxed canonicalization tolerates refolding and whitespace changes.
+	refolded := bytes.Replace(signed, []byte("?=\r\n =?UTF-8?"), []byte("?=\r\n\t  =?UTF-8?"), 1)
+	if err := dkimVerify(&key.PublicKey, refolded); err != nil {
+		t.Errorf("verify failed after refolding: %v", err)
+	}
+}
+
+func TestNewDKIMSignerRequiresDomainAndSelector(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privateKey := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	})
+	configs := []DKIMConfig{
+		{Selector: "mail", PrivateKey: privateKey},
+		{Domain: "lenslocked.com", PrivateKey: privateKey},
+	}
+	for _, config := range configs {
+		_, err := NewDKIMSigner(config)
+		if err == nil {
+			t.Errorf("NewDKIMSigner(%+v) err = nil, want an error", config)
+		}
+	}
+}
+
+// dkimVerify checks the first DKIM-Signature header of a signed message the
+// way a receiver would.
+func dkimVerify(pub *rsa.PublicKey, signed []byte) error {
+	header, body, _ := bytes.Cut(signed, []byte("\r\n\r\n"))
+	sigField, rest, _ := strings.Cut(string(header), "\r\n")
+	_, value, _ := strings.Cut(sigField, ":")
+
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(value, ";") {
+		k, v, _ := strings.Cut(strings.TrimSpace(tag), "=")
+		tags[k] = v
+	}
+	if tags["d"] != "lenslocked.com" || tags["s"] != "mail" || tags["c"] != "relaxed/relaxed" {
+		return fmt.Errorf("unexpected tags %v", tags)
+	}
+	if !strings.Contains(":"+tags["h"]+":", ":subject:") {
+		return fmt.Errorf("h = %s, want subject signed", tags["h"])
+	}
+
+	bodyHash := sha256.Sum256(dkimRelaxedBody(body))
+	if got := base64.StdEncoding.EncodeToString(bodyHash[:]); got != tags["bh"] {
+		return fmt.Errorf("bh = %s, want %s", tags["bh"], got)
+	}
+
+	fields := dkimHeaderFields([]byte(rest))
+	var data bytes.Buffer
+	for _, name := range strings.Split(tags["h"], ":") {
+		data.WriteString(dkimRelaxedHeader(fields[name]))
+		data.WriteString("\r\n")
+	}
+	unsigned := sigField[:strings.Index(sigField, "b="+tags["b"])+2]
+	data.WriteString(dkimRelaxedHeader(unsigned))
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(data.Bytes())
+	return rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+}
@@ -1,9 +1,15 @@
 package models
 
 import (
+	"bytes"
+	"database/sql"
 	"fmt"
+	"io"
+	netmail "net/mail"
+	"strings"
 
 	"github.com/go-mail/mail/v2"
+	"github.com/grayjunzi/lenslocked/rand"
 )
 
 const (
@@ -16,6 +22,13 @@ type Email struct {
 	Subject   string
 	PlainText string
 	HTML      string
+	// UnsubscribeURL is set on non-transactional emails and is advertised
+	// with List-Unsubscribe headers for one-click unsubscribe (RFC 8058).
+	UnsubscribeURL string
+	// Sensitive emails carry secrets such as password reset tokens. The
+	// outbox clears their body once they are sent or dead, and they cannot
+	// be resent.
+	Sensitive bool
 }
 
 type SMTPConfig struct {
@@ -27,24 +40,46 @@ type SMTPConfig struct {
 
 type EmailService struct {
 	DefaultSender string
+	DKIM          *DKIMSigner
 
-	dialer *mail.Dialer
+	mailer    Mailer
+	templates *EmailTemplates
 }
 
-func NewEmailService(config SMTPConfig) *EmailService {
+func NewEmailService(mailer Mailer, templates *EmailTemplates) *EmailService {
 	return &EmailService{
-		dialer: mail.NewDialer(config.Host, config.Port, config.Username, config.Password),
+		mailer:    mailer,
+		templates: templates,
 	}
 }
 
 func (e *EmailService) Send(email Email) error {
 	msg := mail.NewMessage()
 
-	e.setFrom(msg, email)
+	from := e.from(email)
+	fromAddr, err := netmail.ParseAddress(from)
+	if err != nil {
+		return fmt.Errorf("send: from: %w", err)
+	}
+	toAddr, err := netmail.ParseAddress(email.To)
+	if err != nil {
+		return fmt.Errorf("send: to: %w", err)
+	}
+
+	messageID, err := rand.String(16)
+	if err != nil {
+		return fmt.Errorf("send: %w", err)
+	}
+	_, domain, _ := strings.Cut(fromAddr.Address, "@")
 
-	msg.SetHeader("From", email.From)
+	msg.SetHeader("From", from)
 	msg.SetHeader("To", email.To)
 	msg.SetHeader("Subject", email.Subject)
+	msg.SetHeader("Message-ID", "<"+messageID+"@"+domain+">")
+	if email.UnsubscribeURL != "" {
+		msg.SetHeader("List-Unsubscribe", "<"+email.UnsubscribeURL+">")
+		msg.SetHeader("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
 
 	if email.PlainText != "" {
 		msg.SetBody("text/plain", email.PlainText)
@@ -54,22 +89,43 @@ func (e *EmailService) Send(email Email) error {
 		msg.AddAlternative("text/html", email.HTML)
 	}
 
-	if err := e.dialer.DialAndSend(msg); err != nil {
-		return fmt.Errorf("send: %s", err)
+	var raw io.WriterTo = msg
+	if e.DKIM != nil {
+		var buf bytes.Buffer
+		_, err = msg.WriteTo(&buf)
+		if err != nil {
+			return fmt.Errorf("send: %w", err)
+		}
+		signed, err := e.DKIM.Sign(buf.Bytes())
+		if err != nil {
+			return fmt.Errorf("send: %w", err)
+		}
+		raw = bytes.NewReader(signed)
+	}
+
+	err = e.mailer.Send(fromAddr.Address, []string{toAddr.Address}, raw)
+	if err != nil {
+		return fmt.Errorf("send: %w", err)
 	}
 
 	return nil
 }
 
-func (e *EmailService) ForgotPassword(to, resetURL string) error {
-	email := Email{
-		Subject:   "Reset your password",
-		To:        to,
-		PlainText: "To reset your password, please visit the following link:" + resetURL,
-		HTML:      `<p>To reset your password, pleanse visit the following link: <a href="` + resetURL + `">` + resetURL + `</a></p>`,
+type ForgotPasswordEmail struct {
+	ResetURL string
+}
+
+func (e *EmailService) ForgotPassword(tx *sql.Tx, locale, to, resetURL string) error {
+	email, err := e.templates.Render("forgot-password", locale, ForgotPasswordEmail{
+		ResetURL: resetURL,
+	})
+	if err != nil {
+		return fmt.Errorf("forgot password email: %w", err)
 	}
+	email.To = to
+	email.Sensitive = true
 
-	err := e.Send(email)
+	err = e.Enqueue(tx, email)
 	if err != nil {
 		return fmt.Errorf("forgot password email: %w", err)
 	}
@@ -77,15 +133,61 @@ func (e *EmailService) ForgotPassword(to, resetURL string) error {
 	return nil
 }
 
-func (e *EmailService) setFrom(msg *mail.Message, email Email) {
-	var from string
+func (e *EmailService) from(email Email) string {
 	switch {
 	case email.From != "":
-		from = email.From
+		return email.From
 	case e.DefaultSender != "":
-		from = e.DefaultSender
+		return e.DefaultSender
 	default:
-		from = DefaultSender
+		return DefaultSender
 	}
-	msg.SetHeader("From", from)
+}
+
+type NotificationEmail struct {
+	Notification   Notification
+	UnsubscribeURL string
+}
+
+func (e *EmailService) Notification(tx *sql.Tx, locale, to string, n Notification, unsubscribeURL string) error {
+	email, err := e.templates.Render("notification", locale, NotificationEmail{
+		Notification:   n,
+		UnsubscribeURL: unsubscribeURL,
+	})
+	if err != nil {
+		return fmt.Errorf("notification email: %w", err)
+	}
+	email.To = to
+	email.UnsubscribeURL = unsubscribeURL
+
+	err = e.Enqueue(tx, email)
+	if err != nil {
+		return fmt.Errorf("notification email: %w", err)
+	}
+
+	return nil
+}
+
+type DigestEmail struct {
+	Notifications  []Notification
+	UnsubscribeURL string
+}
+
+func (e *EmailService) Digest(tx *sql.Tx, locale, to string, notifications []Notification, unsubscribeURL string) error {
+	email, err := e.templates.Render("digest", locale, DigestEmail{
+		Notifications:  notifications,
+		UnsubscribeURL: unsubscribeURL,
+	})
+	if err != nil {
+		return fmt.Errorf("digest email: %w", err)
+	}
+	email.To = to
+	email.UnsubscribeURL = unsubscribeURL
+
+	err = e.Enqueue(tx, email)
+	if err != nil {
+		return fmt.Errorf("digest email: %w", err)
+	}
+
+	return nil
 }
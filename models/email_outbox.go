@@ -0,0 +1,254 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	OutboxPending    = "pending"
+	OutboxSent       = "sent"
+	OutboxDead       = "dead"
+	OutboxSuppressed = "suppressed"
+)
+
+const (
+	DefaultOutboxMaxAttempts = 8
+	DefaultOutboxBackoff     = 30 * time.Second
+	MaxOutboxBackoff         = 6 * time.Hour
+	// outboxLease is how long a worker owns a claimed email before another
+	// worker may pick it up again.
+	outboxLease = 10 * time.Minute
+)
+
+var ErrNotResendable = errors.New("models: email cannot be resent")
+
+type OutboxEmail struct {
+	ID            int
+	Email         Email
+	Status        string
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+	SentAt        *time.Time
+}
+
+// Enqueue stores the email in the outbox as part of tx, so it is only sent
+// if the change that triggered it commits.
+func (e *EmailService) Enqueue(tx *sql.Tx, email Email) error {
+	_, err := tx.Exec(`
+		INSERT INTO email_outbox (from_address, to_address, subject, plain_text, html, unsubscribe_url, sensitive)
+		VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`, e.from(email), email.To, email.Subject, email.PlainText, email.HTML, email.UnsubscribeURL, email.Sensitive)
+	if err != nil {
+		return fmt.Errorf("enqueue email: %w", err)
+	}
+	return nil
+}
+
+type EmailOutboxService struct {
+	DB           *sql.DB
+	EmailService *EmailService
+	// Suppressions, when set, stops non-critical emails (those with an
+	// UnsubscribeURL) to addresses that bounced or complained.
+	Suppressions *EmailSuppressionService
+	MaxAttempts  int
+	Backoff      time.Duration
+}
+
+// Run delivers due emails every interval until ctx is canceled.
+func (s *EmailOutboxService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, err := s.DeliverPending(100)
+		if err != nil {
+			log.Printf("email outbox: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverPending sends up to limit due emails and returns how many were
+// delivered. Failed emails are retried with exponential backoff until they
+// run out of attempts and are marked dead.
+func (s *EmailOutboxService) DeliverPending(limit int) (int, error) {
+	rows, err := s.DB.Query(`
+		UPDATE email_outbox
+		SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE status = $3 AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, from_address, to_address, subject, plain_text, html, unsubscribe_url, sensitive, attempts;
+	`, limit, time.Now().Add(outboxLease), OutboxPending)
+	if err != nil {
+		return 0, fmt.Errorf("deliver pending: %w", err)
+	}
+	var claimed []OutboxEmail
+	for rows.Next() {
+		var oe OutboxEmail
+		err = rows.Scan(&oe.ID, &oe.Email.From, &oe.Email.To, &oe.Email.Subject,
+			&oe.Email.PlainText, &oe.Email.HTML, &oe.Email.UnsubscribeURL, &oe.Email.Sensitive, &oe.Attempts)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("deliver pending: %w", err)
+		}
+		claimed = append(claimed, oe)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("deliver pending: %w", err)
+	}
+
+	delivered := 0
+	for _, oe := range claimed {
+		if oe.Email.UnsubscribeURL != "" && s.Suppressions != nil {
+			suppression, err := s.Suppressions.ForEmail(oe.Email.To)
+			if err != nil {
+				return delivered, fmt.Errorf("deliver pending: %w", err)
+			}
+			if suppression != nil {
+				err = s.markSuppressed(oe.ID, suppression)
+				if err != nil {
+					return delivered, fmt.Errorf("deliver pending: %w", err)
+				}
+				continue
+			}
+		}
+		sendErr := s.EmailService.Send(oe.Email)
+		if sendErr == nil {
+			err = s.markSent(oe.ID)
+			delivered++
+		} else {
+			err = s.markFailed(oe, sendErr)
+		}
+		if err != nil {
+			return delivered, fmt.Errorf("deliver pending: %w", err)
+		}
+	}
+	return delivered, nil
+}
+
+// markSent also clears the body. Sent emails are never resent, and keeping
+// them would leave secrets such as reset tokens in the database.
+func (s *EmailOutboxService) markSent(id int) error {
+	_, err := s.DB.Exec(`
+		UPDATE email_outbox
+		SET status = $2, attempts = attempts + 1, last_error = '', sent_at = NOW(),
+			plain_text = '', html = ''
+		WHERE id = $1;
+	`, id, OutboxSent)
+	return err
+}
+
+func (s *EmailOutboxService) markSuppressed(id int, suppression *EmailSuppression) error {
+	_, err := s.DB.Exec(`
+		UPDATE email_outbox
+		SET status = $2, last_error = $3
+		WHERE id = $1;
+	`, id, OutboxSuppressed, "recipient suppressed after "+suppression.Reason)
+	return err
+}
+
+func (s *EmailOutboxService) markFailed(oe OutboxEmail, sendErr error) error {
+	maxAttempts := s.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultOutboxMaxAttempts
+	}
+	attempts := oe.Attempts + 1
+	status := OutboxPending
+	if attempts >= maxAttempts {
+		status = OutboxDead
+	}
+	// Dead sensitive emails cannot be resent, so their body is cleared.
+	_, err := s.DB.Exec(`
+		UPDATE email_outbox
+		SET status = $2, attempts = $3, last_error = $4, next_attempt_at = $5,
+			plain_text = CASE WHEN $6 THEN '' ELSE plain_text END,
+			html = CASE WHEN $6 THEN '' ELSE html END
+		WHERE id = $1;
+	`, oe.ID, status, attempts, sendErr.Error(), time.Now().Add(s.backoff(attempts)),
+		status == OutboxDead && oe.Email.Sensitive)
+	return err
+}
+
+func (s *EmailOutboxService) backoff(attempts int) time.Duration {
+	backoff := s.Backoff
+	if backoff <= 0 {
+		backoff = DefaultOutboxBackoff
+	}
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= MaxOutboxBackoff {
+			return MaxOutboxBackoff
+		}
+	}
+	return backoff
+}
+
+// List returns the most recent outbox emails with the given status, or with
+// any status if status is empty.
+func (s *EmailOutboxService) List(status string, limit int) ([]OutboxEmail, error) {
+	rows, err := s.DB.Query(`
+		SELECT id, from_address, to_address, subject, sensitive, status, attempts,
+			next_attempt_at, last_error, created_at, sent_at
+		FROM email_outbox
+		WHERE $1 = '' OR status = $1
+		ORDER BY id DESC
+		LIMIT $2;
+	`, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list outbox: %w", err)
+	}
+	defer rows.Close()
+	var emails []OutboxEmail
+	for rows.Next() {
+		var oe OutboxEmail
+		err = rows.Scan(&oe.ID, &oe.Email.From, &oe.Email.To, &oe.Email.Subject, &oe.Email.Sensitive, &oe.Status,
+			&oe.Attempts, &oe.NextAttemptAt, &oe.LastError, &oe.CreatedAt, &oe.SentAt)
+		if err != nil {
+			return nil, fmt.Errorf("list outbox: %w", err)
+		}
+		emails = append(emails, oe)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("list outbox: %w", err)
+	}
+	return emails, nil
+}
+
+// Resend puts a dead or suppressed email back in the queue to be delivered
+// as soon as possible, with a fresh set of attempts. Sensitive emails cannot
+// be resent: their body is gone and any token in it may have expired or been
+// replaced, so the user has to request a new one.
+func (s *EmailOutboxService) Resend(id int) error {
+	res, err := s.DB.Exec(`
+		UPDATE email_outbox
+		SET status = $2, attempts = 0, next_attempt_at = NOW()
+		WHERE id = $1 AND status IN ($3, $4) AND NOT sensitive;
+	`, id, OutboxPending, OutboxDead, OutboxSuppressed)
+	if err != nil {
+		return fmt.Errorf("resend: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("resend: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("resend: %w", ErrNotResendable)
+	}
+	return nil
+}
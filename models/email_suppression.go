@@ -0,0 +1,228 @@
+package models
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	netmail "net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+const (
+	FeedbackBounce    = "bounce"
+	FeedbackComplaint = "complaint"
+)
+
+// EmailFeedback is a single bounce or complaint reported for an address.
+type EmailFeedback struct {
+	Type      string `json:"type"`
+	Email     string `json:"email"`
+	Permanent bool   `json:"permanent"`
+	Detail    string `json:"detail"`
+}
+
+type EmailSuppression struct {
+	ID        int
+	Email     string
+	Reason    string
+	Detail    string
+	CreatedAt time.Time
+}
+
+// EmailSuppressionService tracks addresses that bounced permanently or
+// complained. Non-critical mail to them is not delivered.
+type EmailSuppressionService struct {
+	DB *sql.DB
+}
+
+// Record applies a batch of feedback in one transaction, so a failed
+// request can be retried without applying part of it twice. Complaints and
+// permanent bounces suppress the address; temporary bounces are ignored
+// since the outbox already retries them. Items with an invalid address are
+// logged and skipped, as retrying them would never succeed.
+func (ss *EmailSuppressionService) Record(feedback []EmailFeedback) error {
+	tx, err := ss.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("record feedback: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, fb := range feedback {
+		if fb.Type != FeedbackComplaint && !(fb.Type == FeedbackBounce && fb.Permanent) {
+			continue
+		}
+		addr, err := netmail.ParseAddress(fb.Email)
+		if err != nil {
+			log.Printf("record feedback: skipping %q: %v", fb.Email, err)
+			continue
+		}
+		_, err = tx.Exec(`
+			INSERT INTO email_suppressions (email, reason, detail)
+			VALUES ($1, $2, $3) ON CONFLICT (email) DO
+			UPDATE
+			SET reason = $2, detail = $3, created_at = NOW();
+		`, strings.ToLower(addr.Address), fb.Type, fb.Detail)
+		if err != nil {
+			return fmt.Errorf("record feedback: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("record feedback: %w", err)
+	}
+	return nil
+}
+
+// ForEmail returns the suppression for the address, or nil if mail to it is
+// delivered normally.
+func (ss *EmailSuppressionService) ForEmail(email string) (*EmailSuppression, error) {
+	if addr, err := netmail.ParseAddress(email); err == nil {
+		email = addr.Address
+	}
+	suppression := EmailSuppression{
+		Email: strings.ToLower(email),
+	}
+	row := ss.DB.QueryRow(`
+		SELECT id, reason, detail, created_at
+		FROM email_suppressions
+		WHERE email = $1;
+	`, suppression.Email)
+	err := row.Scan(&suppression.ID, &suppression.Reason, &suppression.Detail, &suppression.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("suppression for email: %w", err)
+	}
+	return &suppression, nil
+}
+
+func (ss *EmailSuppressionService) Remove(email string) error {
+	_, err := ss.DB.Exec(`
+		DELETE FROM email_suppressions
+		WHERE email = $1;
+	`, strings.ToLower(email))
+	if err != nil {
+		return fmt.Errorf("remove suppression: %w", err)
+	}
+	return nil
+}
+
+// ParseFeedbackReport reads a multipart/report message, either a delivery
+// status notification (RFC 3464) or an abuse feedback report (RFC 5965), and
+// returns the feedback it carries.
+func ParseFeedbackReport(r io.Reader) ([]EmailFeedback, error) {
+	msg, err := netmail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("parse feedback report: %w", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, fmt.Errorf("parse feedback report: %w", err)
+	}
+	if mediaType != "multipart/report" {
+		return nil, fmt.Errorf("parse feedback report: unexpected content type %q", mediaType)
+	}
+
+	var feedback []EmailFeedback
+	var complaint *EmailFeedback
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse feedback report: %w", err)
+		}
+		partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		switch partType {
+		case "message/delivery-status":
+			bounces, err := parseDeliveryStatus(part)
+			if err != nil {
+				return nil, fmt.Errorf("parse feedback report: %w", err)
+			}
+			feedback = append(feedback, bounces...)
+		case "message/feedback-report":
+			fields, err := textproto.NewReader(bufio.NewReader(part)).ReadMIMEHeader()
+			if err != nil && !errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("parse feedback report: %w", err)
+			}
+			complaint = &EmailFeedback{
+				Type:   FeedbackComplaint,
+				Email:  stripAddressType(fields.Get("Original-Rcpt-To")),
+				Detail: fields.Get("Feedback-Type"),
+			}
+		case "message/rfc822", "text/rfc822-headers":
+			// Complaint reports often omit Original-Rcpt-To, leaving the
+			// recipient only in the returned message.
+			if complaint == nil || complaint.Email != "" {
+				continue
+			}
+			original, err := netmail.ReadMessage(part)
+			if err != nil {
+				continue
+			}
+			complaint.Email = original.Header.Get("To")
+			if addr, err := netmail.ParseAddress(complaint.Email); err == nil {
+				complaint.Email = addr.Address
+			}
+		}
+	}
+	if complaint != nil {
+		if complaint.Email == "" {
+			return nil, errors.New("parse feedback report: complaint without recipient")
+		}
+		feedback = append(feedback, *complaint)
+	}
+	return feedback, nil
+}
+
+// parseDeliveryStatus reads the per-message fields followed by one block of
+// fields per recipient.
+func parseDeliveryStatus(r io.Reader) ([]EmailFeedback, error) {
+	tp := textproto.NewReader(bufio.NewReader(r))
+	_, err := tp.ReadMIMEHeader()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	var feedback []EmailFeedback
+	for !errors.Is(err, io.EOF) {
+		var fields textproto.MIMEHeader
+		fields, err = tp.ReadMIMEHeader()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		recipient := stripAddressType(fields.Get("Final-Recipient"))
+		if recipient == "" || !strings.EqualFold(fields.Get("Action"), "failed") {
+			continue
+		}
+		detail := fields.Get("Diagnostic-Code")
+		if detail == "" {
+			detail = fields.Get("Status")
+		}
+		feedback = append(feedback, EmailFeedback{
+			Type:      FeedbackBounce,
+			Email:     recipient,
+			Permanent: strings.HasPrefix(strings.TrimSpace(fields.Get("Status")), "5"),
+			Detail:    detail,
+		})
+	}
+	return feedback, nil
+}
+
+// stripAddressType turns "rfc822; user@example.com" into "user@example.com".
+func stripAddressType(field string) string {
+	if _, addr, ok := strings.Cut(field, ";"); ok {
+		field = addr
+	}
+	return strings.Trim(strings.TrimSpace(field), "<>")
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFeedbackReport(t *testing.T) {
+	tests := []struct {
+		file string
+		want []EmailFeedback
+	}{
+		{
+			file: "testdata/dsn-multi-recipient.eml",
+			want: []EmailFeedback{
+				{
+					Type:      FeedbackBounce,
+					Email:     "nobody@example.com",
+					Permanent: true,
+					Detail:    "smtp; 550 5.1.1 <nobody@example.com>: Recipient address rejected: User unknown in virtual mailbox table",
+				},
+				{
+					Type:      FeedbackBounce,
+					Email:     "jane@example.org",
+					Permanent: false,
+					Detail:    "X-Postfix; delivery temporarily suspended: connect to mx.example.org[203.0.113.7]:25: Connection timed out",
+				},
+			},
+		},
+		{
+			file: "testdata/arf-no-original-rcpt-to.eml",
+			want: []EmailFeedback{
+				{
+					Type:   FeedbackComplaint,
+					Email:  "Jane@Example.com",
+					Detail: "abuse",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		raw, err := os.ReadFile(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// MTAs pipe reports with bare LF line endings; on the wire they use
+		// CRLF. Both must parse the same.
+		for name, report := range map[string]string{
+			"lf":   string(raw),
+			"crlf": strings.ReplaceAll(string(raw), "\n", "\r\n"),
+		} {
+			t.Run(tt.file+"/"+name, func(t *testing.T) {
+				got, err := ParseFeedbackReport(strings.NewReader(report))
+				if err != nil {
+					t.Fatalf("ParseFeedbackReport() err = %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("ParseFeedbackReport() =\n%+v\nwant\n%+v", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestParseFeedbackReportRejectsOtherMessages(t *testing.T) {
+	msg := "From: jane@example.com\r\nContent-Type: text/plain\r\n\r\nhello\r\n"
+	_, err := ParseFeedbackReport(strings.NewReader(msg))
+	if err == nil {
+		t.Errorf("ParseFeedbackReport() err = nil, want an error")
+	}
+}
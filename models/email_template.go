@@ -0,0 +1,124 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/grayjunzi/lenslocked/i18n"
+)
+
+const (
+	emailHTMLLayout = "layout.gohtml"
+	emailTextLayout = "layout.gotext"
+)
+
+// EmailTemplates renders emails from pairs of name.gotext and name.gohtml
+// files that share layout.gotext and layout.gohtml. The text file must define
+// "subject" and "content", the HTML file defines "content". Both can use the
+// t, date and number functions, bound to the locale passed to Render.
+type EmailTemplates struct {
+	html map[string]*htmltemplate.Template
+	text map[string]*texttemplate.Template
+}
+
+func ParseEmailTemplates(fsys fs.FS) (*EmailTemplates, error) {
+	files, err := fs.Glob(fsys, "*.gotext")
+	if err != nil {
+		return nil, fmt.Errorf("parse email templates: %w", err)
+	}
+	templates := EmailTemplates{
+		html: make(map[string]*htmltemplate.Template),
+		text: make(map[string]*texttemplate.Template),
+	}
+	for _, file := range files {
+		if file == emailTextLayout {
+			continue
+		}
+		name := strings.TrimSuffix(file, ".gotext")
+		textTpl, err := texttemplate.New(emailTextLayout).
+			Funcs(emailFuncs(i18n.Default)).
+			ParseFS(fsys, emailTextLayout, file)
+		if err != nil {
+			return nil, fmt.Errorf("parse email templates: %w", err)
+		}
+		htmlTpl, err := htmltemplate.New(emailHTMLLayout).
+			Funcs(htmltemplate.FuncMap(emailFuncs(i18n.Default))).
+			ParseFS(fsys, emailHTMLLayout, name+".gohtml")
+		if err != nil {
+			return nil, fmt.Errorf("parse email templates: %w", err)
+		}
+		templates.text[name] = textTpl
+		templates.html[name] = htmlTpl
+	}
+	return &templates, nil
+}
+
+func (t *EmailTemplates) Names() []string {
+	names := make([]string, 0, len(t.text))
+	for name := range t.text {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render executes the named email in locale with data and returns an Email
+// with the subject and both bodies set. The caller fills in the recipient.
+func (t *EmailTemplates) Render(name, locale string, data interface{}) (Email, error) {
+	textTpl, ok := t.text[name]
+	if !ok {
+		return Email{}, fmt.Errorf("render email: unknown template %q", name)
+	}
+	textTpl, err := textTpl.Clone()
+	if err != nil {
+		return Email{}, fmt.Errorf("render email %s: %w", name, err)
+	}
+	textTpl = textTpl.Funcs(emailFuncs(locale))
+	htmlTpl, err := t.html[name].Clone()
+	if err != nil {
+		return Email{}, fmt.Errorf("render email %s: %w", name, err)
+	}
+	htmlTpl = htmlTpl.Funcs(htmltemplate.FuncMap(emailFuncs(locale)))
+
+	var subject, text, html bytes.Buffer
+	err = textTpl.ExecuteTemplate(&subject, "subject", data)
+	if err != nil {
+		return Email{}, fmt.Errorf("render email %s: %w", name, err)
+	}
+	err = textTpl.ExecuteTemplate(&text, emailTextLayout, data)
+	if err != nil {
+		return Email{}, fmt.Errorf("render email %s: %w", name, err)
+	}
+	err = htmlTpl.ExecuteTemplate(&html, emailHTMLLayout, data)
+	if err != nil {
+		return Email{}, fmt.Errorf("render email %s: %w", name, err)
+	}
+	return Email{
+		Subject:   strings.TrimSpace(subject.String()),
+		PlainText: strings.TrimSpace(text.String()),
+		HTML:      html.String(),
+	}, nil
+}
+
+func emailFuncs(locale string) texttemplate.FuncMap {
+	return texttemplate.FuncMap{
+		"locale": func() string {
+			return locale
+		},
+		"t": func(key string, args ...interface{}) string {
+			return i18n.T(locale, key, args...)
+		},
+		"date": func(t time.Time) string {
+			return i18n.FormatDate(locale, t)
+		},
+		"number": func(n interface{}) string {
+			return i18n.FormatNumber(locale, n)
+		},
+	}
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"bytes"
+	"mime"
+	netmail "net/mail"
+	"strings"
+	"testing"
+)
+
+func TestEmailServiceSend(t *testing.T) {
+	mailer := &MemoryMailer{}
+	es := NewEmailService(mailer, nil)
+
+	err := es.Send(Email{
+		To:             "Jane <Jane@example.com>",
+		Subject:        "重置你的密码",
+		PlainText:      "hello",
+		HTML:           "<p>hello</p>",
+		UnsubscribeURL: "https://lenslocked.com/unsubscribe?token=abc",
+	})
+	if err != nil {
+		t.Fatalf("Send() err = %v", err)
+	}
+
+	sent := mailer.Messages()
+	if len(sent) != 1 {
+		t.Fatalf("len(Messages()) = %d, want 1", len(sent))
+	}
+	if sent[0].From != DefaultSender {
+		t.Errorf("envelope from = %q, want %q", sent[0].From, DefaultSender)
+	}
+	if len(sent[0].To) != 1 || sent[0].To[0] != "Jane@example.com" {
+		t.Errorf("envelope to = %q, want [Jane@example.com]", sent[0].To)
+	}
+
+	msg, err := netmail.ReadMessage(bytes.NewReader(sent[0].Raw))
+	if err != nil {
+		t.Fatalf("ReadMessage() err = %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("decoding subject: %v", err)
+	}
+	headers := map[string]string{
+		"From":                  DefaultSender,
+		"To":                    "Jane <Jane@example.com>",
+		"List-Unsubscribe":      "<https://lenslocked.com/unsubscribe?token=abc>",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+	for name, want := range headers {
+		if got := msg.Header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if subject != "重置你的密码" {
+		t.Errorf("Subject = %q, want %q", subject, "重置你的密码")
+	}
+	messageID := msg.Header.Get("Message-ID")
+	if !strings.HasPrefix(messageID, "<") || !strings.HasSuffix(messageID, "@lenslocked.com>") {
+		t.Errorf("Message-ID = %q, want <...@lenslocked.com>", messageID)
+	}
+	if !strings.HasPrefix(msg.Header.Get("Content-Type"), "multipart/alternative") {
+		t.Errorf("Content-Type = %q, want multipart/alternative", msg.Header.Get("Content-Type"))
+	}
+}
+
+func TestEmailServiceSendTransactional(t *testing.T) {
+	mailer := &MemoryMailer{}
+	es := NewEmailService(mailer, nil)
+	es.DefaultSender = "Lenslocked <noreply@lenslocked.com>"
+
+	err := es.Send(Email{
+		To:        "jane@example.com",
+		Subject:   "Reset your password",
+		PlainText: "hello",
+	})
+	if err != nil {
+		t.Fatalf("Send() err = %v", err)
+	}
+
+	sent := mailer.Messages()
+	if len(sent) != 1 {
+		t.Fatalf("len(Messages()) = %d, want 1", len(sent))
+	}
+	if sent[0].From != "noreply@lenslocked.com" {
+		t.Errorf("envelope from = %q, want %q", sent[0].From, "noreply@lenslocked.com")
+	}
+	msg, err := netmail.ReadMessage(bytes.NewReader(sent[0].Raw))
+	if err != nil {
+		t.Fatalf("ReadMessage() err = %v", err)
+	}
+	if got := msg.Header.Get("From"); got != es.DefaultSender {
+		t.Errorf("From = %q, want %q", got, es.DefaultSender)
+	}
+	if got := msg.Header.Get("List-Unsubscribe"); got != "" {
+		t.Errorf("List-Unsubscribe = %q, want none on transactional mail", got)
+	}
+
+	mailer.Reset()
+	if len(mailer.Messages()) != 0 {
+		t.Errorf("Messages() after Reset() is not empty")
+	}
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-mail/mail/v2"
+	"github.com/grayjunzi/lenslocked/rand"
+)
+
+const (
+	MailerSMTP   = "smtp"
+	MailerFile   = "file"
+	MailerStdout = "stdout"
+)
+
+// Mailer delivers a fully formed message to the envelope recipients.
+type Mailer interface {
+	Send(from string, to []string, msg io.WriterTo) error
+}
+
+type MailerConfig struct {
+	Transport string
+	SMTP      SMTPConfig
+	Dir       string
+}
+
+func NewMailer(config MailerConfig) (Mailer, error) {
+	switch config.Transport {
+	case MailerSMTP:
+		return NewSMTPMailer(config.SMTP), nil
+	case MailerFile:
+		if config.Dir == "" {
+			return nil, fmt.Errorf("new mailer: file transport requires a directory")
+		}
+		return &FileMailer{Dir: config.Dir}, nil
+	case MailerStdout:
+		return &StdoutMailer{Writer: os.Stdout}, nil
+	case "":
+		return nil, fmt.Errorf("new mailer: no transport configured")
+	default:
+		return nil, fmt.Errorf("new mailer: unknown transport %q", config.Transport)
+	}
+}
+
+type SMTPMailer struct {
+	dialer *mail.Dialer
+}
+
+func NewSMTPMailer(config SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{
+		dialer: mail.NewDialer(config.Host, config.Port, config.Username, config.Password),
+	}
+}
+
+func (m *SMTPMailer) Send(from string, to []string, msg io.WriterTo) error {
+	s, err := m.dialer.Dial()
+	if err != nil {
+		return fmt.Errorf("smtp send: %w", err)
+	}
+	defer s.Close()
+	err = s.Send(from, to, msg)
+	if err != nil {
+		return fmt.Errorf("smtp send: %w", err)
+	}
+	return nil
+}
+
+// FileMailer writes each message to its own .eml file in Dir, which most
+// mail clients can open directly.
+type FileMailer struct {
+	Dir string
+}
+
+func (m *FileMailer) Send(from string, to []string, msg io.WriterTo) error {
+	err := os.MkdirAll(m.Dir, 0755)
+	if err != nil {
+		return fmt.Errorf("file send: %w", err)
+	}
+	suffix, err := rand.Bytes(4)
+	if err != nil {
+		return fmt.Errorf("file send: %w", err)
+	}
+	name := fmt.Sprintf("%s-%x.eml", time.Now().Format("20060102-150405"), suffix)
+	f, err := os.Create(filepath.Join(m.Dir, name))
+	if err != nil {
+		return fmt.Errorf("file send: %w", err)
+	}
+	defer f.Close()
+	_, err = msg.WriteTo(f)
+	if err != nil {
+		return fmt.Errorf("file send: %w", err)
+	}
+	return f.Close()
+}
+
+type StdoutMailer struct {
+	Writer io.Writer
+
+	mu sync.Mutex
+}
+
+func (m *StdoutMailer) Send(from string, to []string, msg io.WriterTo) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fmt.Fprintf(m.Writer, "---------- email from %s to %s ----------\n", from, strings.Join(to, ", "))
+	_, err := msg.WriteTo(m.Writer)
+	if err != nil {
+		return fmt.Errorf("stdout send: %w", err)
+	}
+	fmt.Fprintln(m.Writer)
+	return nil
+}
+
+type SentMessage struct {
+	From string
+	To   []string
+	Raw  []byte
+}
+
+// MemoryMailer keeps every message it is asked to send, for tests. It never
+// delivers anything and grows without bound, so it is not available as a
+// configured transport.
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []SentMessage
+}
+
+func (m *MemoryMailer) Send(from string, to []string, msg io.WriterTo) error {
+	var buf bytes.Buffer
+	_, err := msg.WriteTo(&buf)
+	if err != nil {
+		return fmt.Errorf("memory send: %w", err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, SentMessage{
+		From: from,
+		To:   append([]string(nil), to...),
+		Raw:  buf.Bytes(),
+	})
+	return nil
+}
+
+func (m *MemoryMailer) Messages() []SentMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]SentMessage(nil), m.messages...)
+}
+
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = nil
+}
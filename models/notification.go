@@ -0,0 +1,292 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grayjunzi/lenslocked/i18n"
+)
+
+const (
+	EventNewComment         = "new_comment"
+	EventProofingSubmission = "proofing_submission"
+	EventCollaboratorUpload = "collaborator_upload"
+	EventStorageWarning     = "storage_warning"
+)
+
+// NotificationEvents lists the non-transactional emails a user can opt out
+// of. Account emails such as password resets are always sent.
+var NotificationEvents = []string{
+	EventNewComment,
+	EventProofingSubmission,
+	EventCollaboratorUpload,
+	EventStorageWarning,
+}
+
+// EventDigest is only used in unsubscribe tokens. Unsubscribing from the
+// digest turns off every event the user gets in their digest.
+const EventDigest = "digest"
+
+const (
+	DeliveryImmediate = "immediate"
+	DeliveryDigest    = "digest"
+	DeliveryNone      = "none"
+)
+
+var ErrInvalidUnsubscribeToken = errors.New("models: invalid unsubscribe token")
+
+type Notification struct {
+	ID        int
+	UserID    int
+	EventType string
+	Title     string
+	Body      string
+	URL       string
+	CreatedAt time.Time
+}
+
+type NotificationService struct {
+	DB           *sql.DB
+	EmailService *EmailService
+	ServerURL    string
+	// Secret signs unsubscribe tokens so links in emails cannot be forged.
+	Secret []byte
+}
+
+// Notify tells the user about an event according to their preferences: an
+// email now, an entry in their next daily digest, or nothing. Nothing calls
+// it yet; the comment, proofing, collaborator and storage features will.
+func (ns *NotificationService) Notify(n Notification) error {
+	if !validNotificationEvent(n.EventType) {
+		return fmt.Errorf("notify: unknown event %q", n.EventType)
+	}
+	var email, locale, delivery string
+	row := ns.DB.QueryRow(`
+		SELECT users.email, users.locale, COALESCE(notification_preferences.delivery, $3)
+		FROM users
+		LEFT JOIN notification_preferences ON notification_preferences.user_id = users.id
+			AND notification_preferences.event_type = $2
+		WHERE users.id = $1;
+	`, n.UserID, n.EventType, DeliveryImmediate)
+	err := row.Scan(&email, &locale, &delivery)
+	if err != nil {
+		return fmt.Errorf("notify: %w", err)
+	}
+
+	tx, err := ns.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("notify: %w", err)
+	}
+	defer tx.Rollback()
+	switch delivery {
+	case DeliveryImmediate:
+		err = ns.EmailService.Notification(tx, userLocale(locale), email, n, ns.unsubscribeURL(n.UserID, n.EventType))
+	case DeliveryDigest:
+		_, err = tx.Exec(`
+			INSERT INTO notifications (user_id, event_type, title, body, url)
+			VALUES ($1, $2, $3, $4, $5);
+		`, n.UserID, n.EventType, n.Title, n.Body, n.URL)
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("notify: %w", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("notify: %w", err)
+	}
+	return nil
+}
+
+// Preferences returns the delivery setting for every notification event,
+// filling in DeliveryImmediate for events the user never changed.
+func (ns *NotificationService) Preferences(userID int) (map[string]string, error) {
+	prefs := make(map[string]string, len(NotificationEvents))
+	for _, event := range NotificationEvents {
+		prefs[event] = DeliveryImmediate
+	}
+	rows, err := ns.DB.Query(`
+		SELECT event_type, delivery
+		FROM notification_preferences
+		WHERE user_id = $1;
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("preferences: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var event, delivery string
+		err = rows.Scan(&event, &delivery)
+		if err != nil {
+			return nil, fmt.Errorf("preferences: %w", err)
+		}
+		prefs[event] = delivery
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+func (ns *NotificationService) Delivery(userID int, event string) (string, error) {
+	delivery := DeliveryImmediate
+	row := ns.DB.QueryRow(`
+		SELECT delivery
+		FROM notification_preferences
+		WHERE user_id = $1 AND event_type = $2;
+	`, userID, event)
+	err := row.Scan(&delivery)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("delivery: %w", err)
+	}
+	return delivery, nil
+}
+
+func (ns *NotificationService) SetDelivery(userID int, event, delivery string) error {
+	if !validNotificationEvent(event) {
+		return fmt.Errorf("set delivery: unknown event %q", event)
+	}
+	if delivery != DeliveryImmediate && delivery != DeliveryDigest && delivery != DeliveryNone {
+		return fmt.Errorf("set delivery: unknown delivery %q", delivery)
+	}
+	tx, err := ns.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("set delivery: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO notification_preferences (user_id, event_type, delivery)
+		VALUES ($1, $2, $3) ON CONFLICT (user_id, event_type) DO
+		UPDATE
+		SET delivery = $3;
+	`, userID, event, delivery)
+	if err != nil {
+		return fmt.Errorf("set delivery: %w", err)
+	}
+	if delivery != DeliveryDigest {
+		err = dropPendingDigest(tx, userID, event)
+		if err != nil {
+			return fmt.Errorf("set delivery: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("set delivery: %w", err)
+	}
+	return nil
+}
+
+// dropPendingDigest deletes notifications waiting for the user's next digest,
+// for one event or, if event is empty, for every event.
+func dropPendingDigest(tx *sql.Tx, userID int, event string) error {
+	_, err := tx.Exec(`
+		DELETE FROM notifications
+		WHERE user_id = $1 AND digested_at IS NULL AND ($2 = '' OR event_type = $2);
+	`, userID, event)
+	return err
+}
+
+// UnsubscribeToken returns a token that turns off emails for event when
+// passed to Unsubscribe.
+func (ns *NotificationService) UnsubscribeToken(userID int, event string) string {
+	payload := strconv.Itoa(userID) + ":" + event
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + ns.sign(payload)
+}
+
+func (ns *NotificationService) Unsubscribe(token string) error {
+	userID, event, err := ns.parseUnsubscribeToken(token)
+	if err != nil {
+		return fmt.Errorf("unsubscribe: %w", err)
+	}
+	if event == EventDigest {
+		tx, err := ns.DB.Begin()
+		if err != nil {
+			return fmt.Errorf("unsubscribe: %w", err)
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Exec(`
+			UPDATE notification_preferences
+			SET delivery = $2
+			WHERE user_id = $1 AND delivery = $3;
+		`, userID, DeliveryNone, DeliveryDigest)
+		if err != nil {
+			return fmt.Errorf("unsubscribe: %w", err)
+		}
+		err = dropPendingDigest(tx, userID, "")
+		if err != nil {
+			return fmt.Errorf("unsubscribe: %w", err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			return fmt.Errorf("unsubscribe: %w", err)
+		}
+		return nil
+	}
+	err = ns.SetDelivery(userID, event, DeliveryNone)
+	if err != nil {
+		return fmt.Errorf("unsubscribe: %w", err)
+	}
+	return nil
+}
+
+func (ns *NotificationService) parseUnsubscribeToken(token string) (int, string, error) {
+	encoded, mac, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, "", ErrInvalidUnsubscribeToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, "", ErrInvalidUnsubscribeToken
+	}
+	if !hmac.Equal([]byte(mac), []byte(ns.sign(string(payload)))) {
+		return 0, "", ErrInvalidUnsubscribeToken
+	}
+	id, event, _ := strings.Cut(string(payload), ":")
+	userID, err := strconv.Atoi(id)
+	if err != nil || (event != EventDigest && !validNotificationEvent(event)) {
+		return 0, "", ErrInvalidUnsubscribeToken
+	}
+	return userID, event, nil
+}
+
+func (ns *NotificationService) unsubscribeURL(userID int, event string) string {
+	vals := url.Values{
+		"token": {ns.UnsubscribeToken(userID, event)},
+	}
+	return ns.ServerURL + "/unsubscribe?" + vals.Encode()
+}
+
+func (ns *NotificationService) sign(payload string) string {
+	h := hmac.New(sha256.New, ns.Secret)
+	h.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// userLocale returns the locale emails to a user are written in.
+func userLocale(locale string) string {
+	if locale == "" {
+		return i18n.Default
+	}
+	return locale
+}
+
+func validNotificationEvent(event string) bool {
+	for _, e := range NotificationEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
@@ -1,9 +1,15 @@
 package models
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/grayjunzi/lenslocked/rand"
 )
 
 const (
@@ -24,10 +30,81 @@ type PasswordResetService struct {
 	Duration      time.Duration
 }
 
-func (p *PasswordResetService) Create(email string) (*PasswordReset, error) {
-	return &PasswordReset{}, fmt.Errorf("")
+// Create stores a new password reset for the user with the given email and
+// calls notify in the same transaction, so the reset and the email telling
+// the user about it are committed together. notify gets the user so the
+// email can be written in their saved locale.
+func (p *PasswordResetService) Create(email string, notify func(tx *sql.Tx, user *User, pwReset *PasswordReset) error) (*PasswordReset, error) {
+	email = strings.ToLower(email)
+	tx, err := p.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("create: %w", err)
+	}
+	defer tx.Rollback()
+
+	user := User{
+		Email: email,
+	}
+	row := tx.QueryRow(`
+		SELECT id, locale FROM users WHERE email = $1;
+	`, email)
+	err = row.Scan(&user.ID, &user.Locale)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("create: %w", ErrUserNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create: %w", err)
+	}
+
+	bytesPerToken := p.BytesPerToken
+	if bytesPerToken < MinBytesPerToken {
+		bytesPerToken = MinBytesPerToken
+	}
+	token, err := rand.String(bytesPerToken)
+	if err != nil {
+		return nil, fmt.Errorf("create: %w", err)
+	}
+	duration := p.Duration
+	if duration == 0 {
+		duration = DefaultResetDuration
+	}
+	pwReset := PasswordReset{
+		UserID:    user.ID,
+		Token:     token,
+		TokenHash: p.hash(token),
+		ExpiresAt: time.Now().Add(duration),
+	}
+	row = tx.QueryRow(`
+		INSERT INTO password_resets (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3) ON CONFLICT (user_id) DO
+		UPDATE
+		SET token_hash = $2, expires_at = $3
+		RETURNING id;
+	`, pwReset.UserID, pwReset.TokenHash, pwReset.ExpiresAt)
+	err = row.Scan(&pwReset.ID)
+	if err != nil {
+		return nil, fmt.Errorf("create: %w", err)
+	}
+
+	if notify != nil {
+		err = notify(tx, &user, &pwReset)
+		if err != nil {
+			return nil, fmt.Errorf("create: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("create: %w", err)
+	}
+	return &pwReset, nil
 }
 
 func (p *PasswordResetService) Consume(token string) (*User, error) {
 	return nil, fmt.Errorf("")
 }
+
+func (p *PasswordResetService) hash(token string) string {
+	tokenHash := sha256.Sum256([]byte(token))
+	return base64.URLEncoding.EncodeToString(tokenHash[:])
+}
@@ -0,0 +1,170 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EventSubscriptionCreated  = "subscription.created"
+	EventSubscriptionRenewed  = "subscription.renewed"
+	EventSubscriptionUpdated  = "subscription.updated"
+	EventSubscriptionCanceled = "subscription.canceled"
+	EventPaymentFailed        = "payment.failed"
+)
+
+const (
+	WebhookSignatureHeader = "Webhook-Signature"
+	WebhookTolerance       = 5 * time.Minute
+)
+
+var ErrInvalidSignature = errors.New("models: invalid webhook signature")
+
+type BillingEvent struct {
+	ID             string    `json:"id"`
+	Type           string    `json:"type"`
+	UserID         int       `json:"user_id"`
+	SubscriptionID string    `json:"subscription_id"`
+	Plan           string    `json:"plan"`
+	PeriodEndsAt   time.Time `json:"period_ends_at"`
+}
+
+type Checkout struct {
+	UserID     int
+	Email      string
+	Plan       Plan
+	SuccessURL string
+	CancelURL  string
+}
+
+type PaymentProvider interface {
+	// CheckoutURL returns the provider's hosted payment page for the checkout.
+	CheckoutURL(checkout Checkout) (string, error)
+	CancelSubscription(subscriptionID string) error
+	// ParseEvent verifies and decodes a webhook request body.
+	ParseEvent(payload []byte, header http.Header) (*BillingEvent, error)
+}
+
+type HostedPaymentConfig struct {
+	CheckoutURL   string
+	APIURL        string
+	APIKey        string
+	WebhookSecret string
+}
+
+// HostedPaymentProvider talks to a payment provider that hosts its own
+// checkout page and reports changes through HMAC signed webhooks.
+type HostedPaymentProvider struct {
+	Client *http.Client
+
+	config HostedPaymentConfig
+}
+
+func NewHostedPaymentProvider(config HostedPaymentConfig) *HostedPaymentProvider {
+	return &HostedPaymentProvider{
+		Client: http.DefaultClient,
+		config: config,
+	}
+}
+
+func (p *HostedPaymentProvider) CheckoutURL(checkout Checkout) (string, error) {
+	u, err := url.Parse(p.config.CheckoutURL)
+	if err != nil {
+		return "", fmt.Errorf("checkout url: %w", err)
+	}
+	vals := url.Values{
+		"plan":                {checkout.Plan.ID},
+		"client_reference_id": {strconv.Itoa(checkout.UserID)},
+		"customer_email":      {checkout.Email},
+		"success_url":         {checkout.SuccessURL},
+		"cancel_url":          {checkout.CancelURL},
+	}
+	u.RawQuery = vals.Encode()
+	return u.String(), nil
+}
+
+func (p *HostedPaymentProvider) CancelSubscription(subscriptionID string) error {
+	endpoint := strings.TrimSuffix(p.config.APIURL, "/") + "/subscriptions/" + url.PathEscape(subscriptionID) + "/cancel"
+	req, err := http.NewRequest(http.MethodPost, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("cancel subscription: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+p.config.APIKey)
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cancel subscription: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("cancel subscription: unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+func (p *HostedPaymentProvider) ParseEvent(payload []byte, header http.Header) (*BillingEvent, error) {
+	if p.config.WebhookSecret == "" {
+		return nil, fmt.Errorf("parse event: webhook secret not configured: %w", ErrInvalidSignature)
+	}
+	err := VerifyWebhook(p.config.WebhookSecret, payload, header.Get(WebhookSignatureHeader), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("parse event: %w", err)
+	}
+	var event BillingEvent
+	err = json.Unmarshal(payload, &event)
+	if err != nil {
+		return nil, fmt.Errorf("parse event: %w", err)
+	}
+	if event.ID == "" || event.Type == "" {
+		return nil, fmt.Errorf("parse event: missing id or type")
+	}
+	return &event, nil
+}
+
+// SignWebhook returns the signature header value for a webhook payload, in
+// the form "t=<unix seconds>,v1=<hex hmac-sha256 of t.payload>".
+func SignWebhook(secret string, payload []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, webhookMAC(secret, ts, payload))
+}
+
+func VerifyWebhook(secret string, payload []byte, signature string, now time.Time) error {
+	var ts, mac string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			mac = value
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || mac == "" {
+		return ErrInvalidSignature
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	if age > WebhookTolerance || age < -WebhookTolerance {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(mac), []byte(webhookMAC(secret, ts, payload))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func webhookMAC(secret, ts string, payload []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyWebhook(t *testing.T) {
+	const secret = "test-secret"
+	payload := []byte(`{"id":"evt_1","type":"subscription.created","user_id":1}`)
+	now := time.Unix(1700000000, 0)
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	tests := []struct {
+		name      string
+		payload   []byte
+		signature string
+		wantErr   bool
+	}{
+		{
+			name:      "valid",
+			payload:   payload,
+			signature: SignWebhook(secret, payload, now),
+		},
+		{
+			name:      "valid within tolerance",
+			payload:   payload,
+			signature: SignWebhook(secret, payload, now.Add(-WebhookTolerance+time.Second)),
+		},
+		{
+			name:      "tampered payload",
+			payload:   []byte(`{"id":"evt_1","type":"subscription.created","user_id":2}`),
+			signature: SignWebhook(secret, payload, now),
+			wantErr:   true,
+		},
+		{
+			name:      "wrong secret",
+			payload:   payload,
+			signature: SignWebhook("other-secret", payload, now),
+			wantErr:   true,
+		},
+		{
+			name:      "stale timestamp",
+			payload:   payload,
+			signature: SignWebhook(secret, payload, now.Add(-WebhookTolerance-time.Second)),
+			wantErr:   true,
+		},
+		{
+			name:      "future timestamp",
+			payload:   payload,
+			signature: SignWebhook(secret, payload, now.Add(WebhookTolerance+time.Second)),
+			wantErr:   true,
+		},
+		{
+			name:      "missing v1",
+			payload:   payload,
+			signature: "t=" + ts,
+			wantErr:   true,
+		},
+		{
+			name:      "missing timestamp",
+			payload:   payload,
+			signature: "v1=" + webhookMAC(secret, ts, payload),
+			wantErr:   true,
+		},
+		{
+			name:      "empty header",
+			payload:   payload,
+			signature: "",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyWebhook(secret, tt.payload, tt.signature, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSignature) {
+					t.Errorf("VerifyWebhook() err = %v, want ErrInvalidSignature", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("VerifyWebhook() err = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestParseEventRequiresSecret(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"subscription.created","user_id":1}`)
+	header := http.Header{}
+	header.Set(WebhookSignatureHeader, SignWebhook("", payload, time.Now()))
+
+	p := NewHostedPaymentProvider(HostedPaymentConfig{})
+	_, err := p.ParseEvent(payload, header)
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("ParseEvent() err = %v, want ErrInvalidSignature", err)
+	}
+}
@@ -57,12 +57,12 @@ func (ss *SessionService) User(token string) (*User, error) {
 	tokenHash := ss.hash(token)
 	var user User
 	row := ss.DB.QueryRow(`
-		SELECT users.id, users.email, users.password_hash
+		SELECT users.id, users.email, users.password_hash, users.timezone, users.locale
 		FROM sessions 
 		JOIN users ON users.id = sessions.user_id
 		WHERE sessions.token_hash = $1
 	`, tokenHash)
-	err := row.Scan(&user.ID, &user.Email, &user.PasswordHash)
+	err := row.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Timezone, &user.Locale)
 	if err != nil {
 		return nil, fmt.Errorf("User: %w", err)
 	}
@@ -0,0 +1,311 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultTrialDuration = 30 * 24 * time.Hour
+	DefaultGracePeriod   = 7 * 24 * time.Hour
+)
+
+const (
+	SubscriptionTrialing = "trialing"
+	SubscriptionActive   = "active"
+	SubscriptionPastDue  = "past_due"
+	SubscriptionCanceled = "canceled"
+)
+
+var (
+	ErrPlanNotFound = errors.New("models: plan not found")
+	ErrTrialUsed    = errors.New("models: trial already used")
+
+	ErrSubscriptionNotFound = errors.New("models: subscription not found")
+)
+
+// Plan names and descriptions are translated in the i18n catalogs under
+// plan.<id>.name and plan.<id>.description.
+type Plan struct {
+	ID         string
+	Name       string
+	PriceCents int
+}
+
+func (p Plan) Free() bool {
+	return p.PriceCents == 0
+}
+
+func (p Plan) PriceDollars() float64 {
+	return float64(p.PriceCents) / 100
+}
+
+var FreePlan = Plan{
+	ID:   "free",
+	Name: "Free",
+}
+
+var Plans = []Plan{
+	FreePlan,
+	{
+		ID:         "basic",
+		Name:       "Basic",
+		PriceCents: 900,
+	},
+	{
+		ID:         "pro",
+		Name:       "Pro",
+		PriceCents: 2900,
+	},
+}
+
+func FindPlan(id string) (Plan, error) {
+	for _, plan := range Plans {
+		if plan.ID == id {
+			return plan, nil
+		}
+	}
+	return Plan{}, fmt.Errorf("find plan %q: %w", id, ErrPlanNotFound)
+}
+
+type Subscription struct {
+	ID                     int
+	UserID                 int
+	Plan                   string
+	Status                 string
+	ProviderSubscriptionID string
+	TrialUsed              bool
+	TrialEndsAt            *time.Time
+	CurrentPeriodEndsAt    *time.Time
+	GraceEndsAt            *time.Time
+	CancelAtPeriodEnd      bool
+}
+
+type SubscriptionService struct {
+	DB            *sql.DB
+	TrialDuration time.Duration
+	GracePeriod   time.Duration
+}
+
+// ActivePlan returns the plan the subscription entitles the user to at the
+// given time. Expired trials, lapsed grace periods and cancellations all fall
+// back to the free plan.
+func (ss *SubscriptionService) ActivePlan(sub *Subscription, now time.Time) Plan {
+	plan, err := FindPlan(sub.Plan)
+	if err != nil {
+		return FreePlan
+	}
+	switch sub.Status {
+	case SubscriptionTrialing:
+		if sub.TrialEndsAt != nil && now.Before(*sub.TrialEndsAt) {
+			return plan
+		}
+	case SubscriptionActive:
+		if sub.CurrentPeriodEndsAt == nil || now.Before(sub.CurrentPeriodEndsAt.Add(ss.gracePeriod())) {
+			return plan
+		}
+	case SubscriptionPastDue:
+		if sub.GraceEndsAt != nil && now.Before(*sub.GraceEndsAt) {
+			return plan
+		}
+	}
+	return FreePlan
+}
+
+func (ss *SubscriptionService) ForUser(userID int) (*Subscription, error) {
+	sub := Subscription{
+		UserID: userID,
+	}
+	row := ss.DB.QueryRow(`
+		SELECT id, plan, status, COALESCE(provider_subscription_id, ''), trial_used,
+			trial_ends_at, current_period_ends_at, grace_ends_at, cancel_at_period_end
+		FROM subscriptions
+		WHERE user_id = $1;
+	`, userID)
+	err := row.Scan(&sub.ID, &sub.Plan, &sub.Status, &sub.ProviderSubscriptionID, &sub.TrialUsed,
+		&sub.TrialEndsAt, &sub.CurrentPeriodEndsAt, &sub.GraceEndsAt, &sub.CancelAtPeriodEnd)
+	if errors.Is(err, sql.ErrNoRows) {
+		sub.Plan = FreePlan.ID
+		sub.Status = SubscriptionActive
+		return &sub, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("subscription for user: %w", err)
+	}
+	return &sub, nil
+}
+
+func (ss *SubscriptionService) StartTrial(userID int, planID string) (*Subscription, error) {
+	plan, err := FindPlan(planID)
+	if err != nil {
+		return nil, fmt.Errorf("start trial: %w", err)
+	}
+	if plan.Free() {
+		return nil, fmt.Errorf("start trial: %w", ErrPlanNotFound)
+	}
+	duration := ss.TrialDuration
+	if duration == 0 {
+		duration = DefaultTrialDuration
+	}
+	trialEndsAt := time.Now().Add(duration)
+	sub := Subscription{
+		UserID:      userID,
+		Plan:        plan.ID,
+		Status:      SubscriptionTrialing,
+		TrialUsed:   true,
+		TrialEndsAt: &trialEndsAt,
+	}
+	row := ss.DB.QueryRow(`
+		INSERT INTO subscriptions (user_id, plan, status, trial_used, trial_ends_at)
+		VALUES ($1, $2, $3, TRUE, $4) ON CONFLICT (user_id) DO
+		UPDATE
+		SET plan = $2, status = $3, trial_used = TRUE, trial_ends_at = $4
+		WHERE subscriptions.trial_used = FALSE
+			AND subscriptions.provider_subscription_id IS NULL
+		RETURNING id;
+	`, sub.UserID, sub.Plan, sub.Status, trialEndsAt)
+	err = row.Scan(&sub.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("start trial: %w", ErrTrialUsed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("start trial: %w", err)
+	}
+	return &sub, nil
+}
+
+// ScheduleCancel records that the provider will stop renewing the
+// subscription. The user keeps the plan until the provider reports the
+// cancellation at the end of the current period.
+func (ss *SubscriptionService) ScheduleCancel(userID int) error {
+	_, err := ss.DB.Exec(`
+		UPDATE subscriptions
+		SET cancel_at_period_end = TRUE
+		WHERE user_id = $1;
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("schedule cancel: %w", err)
+	}
+	return nil
+}
+
+// HandleEvent applies a billing event reported by the payment provider.
+// Events are recorded by their provider ID so redelivered webhooks are
+// applied only once. Events for a subscription that is not known yet, which
+// happens when the provider delivers them out of order, return
+// ErrSubscriptionNotFound without being recorded so the provider retries
+// them.
+func (ss *SubscriptionService) HandleEvent(event BillingEvent) error {
+	tx, err := ss.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("handle event: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`
+		INSERT INTO billing_events (provider_event_id, type)
+		VALUES ($1, $2) ON CONFLICT (provider_event_id) DO NOTHING;
+	`, event.ID, event.Type)
+	if err != nil {
+		return fmt.Errorf("handle event: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("handle event: %w", err)
+	}
+	if n == 0 {
+		return nil
+	}
+
+	switch event.Type {
+	case EventSubscriptionCreated:
+		err = ss.activate(tx, event)
+	case EventSubscriptionRenewed, EventSubscriptionUpdated:
+		err = ss.update(tx, event)
+	case EventPaymentFailed:
+		err = ss.pastDue(tx, event)
+	case EventSubscriptionCanceled:
+		err = ss.cancel(tx, event)
+	}
+	if err != nil {
+		return fmt.Errorf("handle event %s: %w", event.Type, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("handle event: %w", err)
+	}
+	return nil
+}
+
+func (ss *SubscriptionService) activate(tx *sql.Tx, event BillingEvent) error {
+	plan, err := FindPlan(event.Plan)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO subscriptions (user_id, plan, status, provider_subscription_id, current_period_ends_at)
+		VALUES ($1, $2, $3, $4, $5) ON CONFLICT (user_id) DO
+		UPDATE
+		SET plan = $2, status = $3, provider_subscription_id = $4, current_period_ends_at = $5,
+			grace_ends_at = NULL, cancel_at_period_end = FALSE;
+	`, event.UserID, plan.ID, SubscriptionActive, event.SubscriptionID, event.PeriodEndsAt)
+	return err
+}
+
+func (ss *SubscriptionService) update(tx *sql.Tx, event BillingEvent) error {
+	plan, err := FindPlan(event.Plan)
+	if err != nil {
+		return err
+	}
+	res, err := tx.Exec(`
+		UPDATE subscriptions
+		SET plan = $2, status = $3, current_period_ends_at = $4, grace_ends_at = NULL
+		WHERE provider_subscription_id = $1;
+	`, event.SubscriptionID, plan.ID, SubscriptionActive, event.PeriodEndsAt)
+	return requireSubscription(res, err)
+}
+
+func (ss *SubscriptionService) pastDue(tx *sql.Tx, event BillingEvent) error {
+	res, err := tx.Exec(`
+		UPDATE subscriptions
+		SET status = $2, grace_ends_at = $3
+		WHERE provider_subscription_id = $1;
+	`, event.SubscriptionID, SubscriptionPastDue, time.Now().Add(ss.gracePeriod()))
+	return requireSubscription(res, err)
+}
+
+func (ss *SubscriptionService) cancel(tx *sql.Tx, event BillingEvent) error {
+	res, err := tx.Exec(`
+		UPDATE subscriptions
+		SET plan = $2, status = $3, provider_subscription_id = NULL, current_period_ends_at = NULL,
+			grace_ends_at = NULL, cancel_at_period_end = FALSE
+		WHERE provider_subscription_id = $1;
+	`, event.SubscriptionID, FreePlan.ID, SubscriptionCanceled)
+	return requireSubscription(res, err)
+}
+
+func (ss *SubscriptionService) gracePeriod() time.Duration {
+	if ss.GracePeriod == 0 {
+		return DefaultGracePeriod
+	}
+	return ss.GracePeriod
+}
+
+// requireSubscription turns an UPDATE that matched no subscription into
+// ErrSubscriptionNotFound.
+func requireSubscription(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestActivePlan(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	const grace = 3 * 24 * time.Hour
+	ss := &SubscriptionService{GracePeriod: grace}
+
+	tests := []struct {
+		name string
+		sub  Subscription
+		want string
+	}{
+		{
+			name: "no subscription",
+			sub:  Subscription{Plan: FreePlan.ID, Status: SubscriptionActive},
+			want: "free",
+		},
+		{
+			name: "unknown plan",
+			sub:  Subscription{Plan: "enterprise", Status: SubscriptionActive},
+			want: "free",
+		},
+		{
+			name: "trial running",
+			sub:  Subscription{Plan: "pro", Status: SubscriptionTrialing, TrialEndsAt: at(time.Second)},
+			want: "pro",
+		},
+		{
+			name: "trial ends now",
+			sub:  Subscription{Plan: "pro", Status: SubscriptionTrialing, TrialEndsAt: at(0)},
+			want: "free",
+		},
+		{
+			name: "trial without end",
+			sub:  Subscription{Plan: "pro", Status: SubscriptionTrialing},
+			want: "free",
+		},
+		{
+			name: "active in period",
+			sub:  Subscription{Plan: "basic", Status: SubscriptionActive, CurrentPeriodEndsAt: at(24 * time.Hour)},
+			want: "basic",
+		},
+		{
+			name: "active in grace after period",
+			sub:  Subscription{Plan: "basic", Status: SubscriptionActive, CurrentPeriodEndsAt: at(-grace + time.Second)},
+			want: "basic",
+		},
+		{
+			name: "active past grace",
+			sub:  Subscription{Plan: "basic", Status: SubscriptionActive, CurrentPeriodEndsAt: at(-grace)},
+			want: "free",
+		},
+		{
+			name: "past due in grace",
+			sub:  Subscription{Plan: "pro", Status: SubscriptionPastDue, GraceEndsAt: at(time.Second)},
+			want: "pro",
+		},
+		{
+			name: "past due grace ended",
+			sub:  Subscription{Plan: "pro", Status: SubscriptionPastDue, GraceEndsAt: at(0)},
+			want: "free",
+		},
+		{
+			name: "canceled",
+			sub:  Subscription{Plan: "pro", Status: SubscriptionCanceled, CurrentPeriodEndsAt: at(24 * time.Hour)},
+			want: "free",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ss.ActivePlan(&tt.sub, now)
+			if got.ID != tt.want {
+				t.Errorf("ActivePlan() = %q, want %q", got.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestActivePlanDefaultGracePeriod(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	periodEnd := now.Add(-DefaultGracePeriod + time.Second)
+	sub := Subscription{Plan: "basic", Status: SubscriptionActive, CurrentPeriodEndsAt: &periodEnd}
+
+	ss := &SubscriptionService{}
+	if got := ss.ActivePlan(&sub, now); got.ID != "basic" {
+		t.Errorf("ActivePlan() = %q, want %q", got.ID, "basic")
+	}
+	if got := ss.ActivePlan(&sub, now.Add(time.Second)); got.ID != FreePlan.ID {
+		t.Errorf("ActivePlan() = %q, want %q", got.ID, FreePlan.ID)
+	}
+}
@@ -2,16 +2,30 @@ package models
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/jackc/pgconn"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrEmailTaken         = errors.New("models: email address is already in use")
+	ErrInvalidCredentials = errors.New("models: invalid email or password")
+	ErrUserNotFound       = errors.New("models: user not found")
+)
+
+// pgUniqueViolation is the Postgres error code for unique_violation.
+const pgUniqueViolation = "23505"
+
 type User struct {
 	ID           int
 	Email        string
 	PasswordHash string
+	Timezone     string
+	Locale       string
 }
 
 type UserService struct {
@@ -37,6 +51,10 @@ func (us *UserService) Create(email, password string) (*User, error) {
 	`, email, passwordHash)
 	err = row.Scan(&user.ID)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolation {
+			return nil, ErrEmailTaken
+		}
 		return nil, fmt.Errorf("create user: %w", err)
 	}
 	return &user, nil
@@ -51,13 +69,47 @@ func (us *UserService) Authenticate(email, password string) (*User, error) {
 		SELECT id, password_hash FROM users WHERE email=$1
 	`, email)
 	err := row.Scan(&user.ID, &user.PasswordHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidCredentials
+	}
 	if err != nil {
 		return nil, fmt.Errorf("authenticate: %w", err)
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return nil, ErrInvalidCredentials
+	}
 	if err != nil {
 		return nil, fmt.Errorf("authenticate: %w", err)
 	}
 	return &user, nil
 }
+
+func (us *UserService) UpdateTimezone(userID int, timezone string) error {
+	_, err := time.LoadLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("update timezone: %w", err)
+	}
+	_, err = us.DB.Exec(`
+		UPDATE users
+		SET timezone = $2
+		WHERE id = $1;
+	`, userID, timezone)
+	if err != nil {
+		return fmt.Errorf("update timezone: %w", err)
+	}
+	return nil
+}
+
+func (us *UserService) UpdateLocale(userID int, locale string) error {
+	_, err := us.DB.Exec(`
+		UPDATE users
+		SET locale = $2
+		WHERE id = $1;
+	`, userID, locale)
+	if err != nil {
+		return fmt.Errorf("update locale: %w", err)
+	}
+	return nil
+}
@@ -8,9 +8,12 @@ import (
 	"io/fs"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/csrf"
 	"github.com/grayjunzi/lenslocked/context"
+	"github.com/grayjunzi/lenslocked/errors"
+	"github.com/grayjunzi/lenslocked/i18n"
 	"github.com/grayjunzi/lenslocked/models"
 )
 
@@ -31,6 +34,24 @@ func ParseFS(fs fs.FS, patterns ...string) (Template, error) {
 			"currentUser": func() (template.HTML, error) {
 				return "", fmt.Errorf("currentUser not implemented")
 			},
+			"locale": func() (string, error) {
+				return "", fmt.Errorf("locale not implemented")
+			},
+			"t": func(key string, args ...interface{}) (string, error) {
+				return "", fmt.Errorf("t not implemented")
+			},
+			"date": func(t time.Time) (string, error) {
+				return "", fmt.Errorf("date not implemented")
+			},
+			"number": func(n interface{}) (string, error) {
+				return "", fmt.Errorf("number not implemented")
+			},
+			"alerts": func() ([]Alert, error) {
+				return nil, fmt.Errorf("alerts not implemented")
+			},
+			"fieldError": func(field string) (string, error) {
+				return "", fmt.Errorf("fieldError not implemented")
+			},
 		},
 	)
 	tpl, err := tpl.ParseFS(fs, patterns...)
@@ -58,11 +79,26 @@ type Template struct {
 	htmlTpl *template.Template
 }
 
-func (t Template) Execute(w http.ResponseWriter, r *http.Request, data interface{}) {
+// Alert is a message shown at the top of the page.
+type Alert struct {
+	Level   string
+	Message string
+}
+
+// Execute renders the template. errs are shown to the user: errors tied to a
+// form field through errors.Field are available with fieldError, the rest
+// become alerts along with any flash message. Errors without a public
+// message are shown as a generic error.
+//
+// When errs are given the response status is 422 Unprocessable Entity, or
+// 500 Internal Server Error if any of them has no public message.
+func (t Template) Execute(w http.ResponseWriter, r *http.Request, data interface{}, errs ...error) {
+	locale := context.Locale(r.Context())
+	alerts, fieldErrors := messages(r, locale, errs)
 	tpl, err := t.htmlTpl.Clone()
 	if err != nil {
-		log.Printf("cloing template: %v", err)
-		http.Error(w, "There was an error rendering the page.", http.StatusInternalServerError)
+		log.Printf("cloning template: %v", err)
+		http.Error(w, i18n.T(locale, "error.render"), http.StatusInternalServerError)
 		return
 	}
 	tpl = tpl.Funcs(
@@ -73,15 +109,82 @@ func (t Template) Execute(w http.ResponseWriter, r *http.Request, data interface
 			"currentUser": func() *models.User {
 				return context.User(r.Context())
 			},
+			"locale": func() string {
+				return locale
+			},
+			"t": func(key string, args ...interface{}) string {
+				return i18n.T(locale, key, args...)
+			},
+			"date": func(t time.Time) string {
+				return i18n.FormatDate(locale, t)
+			},
+			"number": func(n interface{}) string {
+				return i18n.FormatNumber(locale, n)
+			},
+			"alerts": func() []Alert {
+				return alerts
+			},
+			"fieldError": func(field string) string {
+				return fieldErrors[field]
+			},
 		},
 	)
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	var buf bytes.Buffer
 	err = tpl.Execute(&buf, data)
 	if err != nil {
-		log.Printf("执行模板: %v", err)
-		http.Error(w, "解析模板出错.", http.StatusInternalServerError)
+		log.Printf("executing template: %v", err)
+		http.Error(w, i18n.T(locale, "error.render"), http.StatusInternalServerError)
 		return
 	}
+	if len(errs) > 0 {
+		w.WriteHeader(errorStatus(errs))
+	}
 	io.Copy(w, &buf)
 }
+
+func errorStatus(errs []error) int {
+	for _, err := range errs {
+		var pubErr interface {
+			Public() string
+		}
+		if !errors.As(err, &pubErr) {
+			return http.StatusInternalServerError
+		}
+	}
+	return http.StatusUnprocessableEntity
+}
+
+func messages(r *http.Request, locale string, errs []error) ([]Alert, map[string]string) {
+	var alerts []Alert
+	if flash := context.Flash(r.Context()); flash != nil {
+		alerts = append(alerts, Alert{
+			Level:   flash.Level,
+			Message: i18n.T(locale, flash.Message),
+		})
+	}
+	fieldErrors := make(map[string]string)
+	for _, err := range errs {
+		var pubErr interface {
+			Public() string
+			Field() string
+		}
+		if !errors.As(err, &pubErr) {
+			alerts = append(alerts, Alert{
+				Level:   context.FlashError,
+				Message: i18n.T(locale, "error.generic"),
+			})
+			continue
+		}
+		msg := i18n.T(locale, pubErr.Public())
+		if pubErr.Field() != "" {
+			fieldErrors[pubErr.Field()] = msg
+			continue
+		}
+		alerts = append(alerts, Alert{
+			Level:   context.FlashError,
+			Message: msg,
+		})
+	}
+	return alerts, fieldErrors
+}